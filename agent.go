@@ -2,23 +2,37 @@ package qlap
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"math/rand"
+	"net"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
-	RecordPeriod = 1 * time.Second
+	RecordPeriod           = 1 * time.Second
+	ReconnectMinBackoff    = 100 * time.Millisecond
+	MysqlErrDeadlock       = 1213
+	MysqlErrServerShutdown = 1053
+	MysqlErrServerGone     = 2006
+	MysqlErrServerLost     = 2013
 )
 
+var connectStmt = &statement{sql: "CONNECT", noPrepare: true}
+
 type Agent struct {
 	id          int
 	mysqlConfig *MysqlConfig
-	db          DB
+	conn        *agentConn
 	taskOps     *TaskOpts
 	dataOpts    *DataOpts
 	data        *Data
+	workerConns []*agentConn
 }
 
 func newAgent(id int, myCfg *MysqlConfig, taskOps *TaskOpts, dataOpts *DataOpts) (agent *Agent) {
@@ -32,25 +46,115 @@ func newAgent(id int, myCfg *MysqlConfig, taskOps *TaskOpts, dataOpts *DataOpts)
 	return
 }
 
-func (agent *Agent) prepare(maxIdleConns int, idList []string) error {
+func (agent *Agent) prepare(maxIdleConns int, idList []string, latest *latestKeys, templates []*queryTemplate, table *tableSchema) error {
+	newIdList := make([]string, len(idList))
+	copy(newIdList, idList)
+
+	if agent.dataOpts.KeyDistribution == "" || agent.dataOpts.KeyDistribution == KeyDistributionUniform {
+		rand.Shuffle(len(newIdList), func(i, j int) { newIdList[i], newIdList[j] = newIdList[j], newIdList[i] })
+	}
+
+	agent.data = newData(agent.dataOpts, newIdList, latest, templates, table)
+
+	conn, err := agent.openConn(maxIdleConns)
+
+	if err != nil {
+		return err
+	}
+
+	agent.conn = conn
+
+	if agent.taskOps.OpenLoop {
+		agent.workerConns = make([]*agentConn, agent.taskOps.OpenLoopWorkers)
+
+		for i := range agent.workerConns {
+			// NOTE: Each worker uses its own connection to execute the initial queries on it
+			agent.workerConns[i], err = agent.openConn(1)
+
+			if err != nil {
+				return err
+			}
+
+			agent.workerConns[i].workerId = i + 1
+		}
+	}
+
+	return nil
+}
+
+// Switch the settings for the next phase
+func (agent *Agent) setOpts(taskOps *TaskOpts, dataOpts *DataOpts) {
+	agent.taskOps = taskOps
+	agent.dataOpts = dataOpts
+	agent.data.DataOpts = dataOpts
+}
+
+func (agent *Agent) openConn(maxIdleConns int) (*agentConn, error) {
 	db, err := agent.mysqlConfig.openAndPing(maxIdleConns)
 
 	if err != nil {
 		dsn := agent.mysqlConfig.FormatDSN()
-		return fmt.Errorf("Failed to open/ping DB (agent id=%d, dsn=%s): %w", agent.id, dsn, err)
+		return nil, fmt.Errorf("Failed to open/ping DB (agent id=%d, dsn=%s): %w", agent.id, dsn, err)
 	}
 
-	agent.db = db
+	err = agent.initConn(context.Background(), db)
 
-	newIdList := make([]string, len(idList))
-	copy(newIdList, idList)
-	rand.Shuffle(len(newIdList), func(i, j int) { newIdList[i], newIdList[j] = newIdList[j], newIdList[i] })
-	agent.data = newData(agent.dataOpts, newIdList)
+	if err != nil {
+		return nil, err
+	}
 
+	return agent.newConn(db, maxIdleConns), nil
+}
+
+func (agent *Agent) newConn(db DB, maxIdleConns int) *agentConn {
+	// NOTE: BEGIN, COMMIT and the statements between them must run on the same connection
+	pin := agent.dataOpts.Transaction || agent.dataOpts.CommitRate > 0
+	conn := newAgentConn(db, agent.dataOpts.PreparedStatement, pin)
+	conn.open = agent.connOpener(maxIdleConns)
+
+	return conn
+}
+
+func (agent *Agent) connOpener(maxIdleConns int) func(ctx context.Context) (DB, error) {
+	return func(ctx context.Context) (DB, error) {
+		db, err := agent.mysqlConfig.connect(ctx, maxIdleConns)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = agent.initConn(ctx, db)
+
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		return db, nil
+	}
+}
+
+// Open a worker connection while running in open-loop mode
+func (agent *Agent) addWorkerConn(ctx context.Context) (*agentConn, error) {
+	db, err := agent.connOpener(1)(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn := agent.newConn(db, 1)
+	conn.resetReconnects()
+	agent.workerConns = append(agent.workerConns, conn)
+	conn.workerId = len(agent.workerConns)
+
+	return conn, nil
+}
+
+func (agent *Agent) initConn(ctx context.Context, db DB) error {
 	inits := agent.data.initStmts()
 
 	for _, stmt := range inits {
-		_, err = db.Exec(stmt)
+		_, err := db.ExecContext(ctx, stmt)
 
 		if err != nil {
 			return fmt.Errorf("Failed to execute initial query (agent id=%d, query=%s): %w", agent.id, stmt, err)
@@ -60,18 +164,103 @@ func (agent *Agent) prepare(maxIdleConns int, idList []string) error {
 	return nil
 }
 
+// Whether to reconnect before the i-th query (or transaction) of the connection
+func (agent *Agent) needsReconnect(conn *agentConn, i int) bool {
+	switch agent.taskOps.ConnectionMode {
+	case ConnectionModePerQuery:
+		return conn.closed || i%agent.taskOps.ReconnectEvery == 0
+	case ConnectionModePerTransaction:
+		return true
+	default:
+		return false
+	}
+}
+
+// Reconnect and measure the connect time including the initial queries
+func (agent *Agent) reconnect(ctx context.Context, conn *agentConn, forced bool) (recorderConnectDataPoint, error) {
+	start := time.Now()
+	err := conn.reconnect(ctx, forced)
+	end := time.Now()
+
+	return recorderConnectDataPoint{
+		timestamp: end,
+		agentId:   agent.id,
+		resTime:   end.Sub(start),
+		err:       err,
+	}, err
+}
+
 func (agent *Agent) run(ctx context.Context, recorder *Recorder, token string) error {
-	_, err := agent.db.Exec(fmt.Sprintf("SELECT 'agent(%d) start: token=%s'", agent.id, token))
+	// The connection may be lost in the previous phase
+	if agent.conn.closed {
+		err := agent.conn.reconnect(ctx, false)
+
+		if err != nil {
+			return fmt.Errorf("Failed to reconnect (agent id=%d): %w", agent.id, err)
+		}
+	}
+
+	_, err := agent.conn.db.Exec(fmt.Sprintf("SELECT 'agent(%d) start: token=%s'", agent.id, token))
 
 	if err != nil {
 		return fmt.Errorf("Failed to execute start query (agent id=%d): %w", agent.id, err)
 	}
 
+	for _, conn := range append([]*agentConn{agent.conn}, agent.workerConns...) {
+		conn.resetReconnects()
+	}
+
+	if agent.taskOps.OpenLoop {
+		err = agent.runOpenLoop(ctx, recorder)
+	} else {
+		err = agent.runClosedLoop(ctx, recorder)
+	}
+
+	// NOTE: Count reconnects before the exit query, because the connection is closed on cancel
+	reconnects := 0
+	forced := 0
+
+	// NOTE: Include the workers added while running in open-loop mode
+	for _, conn := range append([]*agentConn{agent.conn}, agent.workerConns...) {
+		reconnects += conn.reconnects()
+		forced += conn.forcedCnt
+	}
+
+	recorder.addReconnects(agent.id, reconnects, forced)
+
+	// NOTE: The query canceled at the end of the test may break the pinned connection
+	if agent.conn.pinned != nil {
+		agent.conn.unpin()
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to transact (agent id=%d): %w", agent.id, err)
+	}
+
+	// NOTE: The test finished before recovering from the lost connection
+	if agent.conn.closed {
+		return nil
+	}
+
+	_, err = agent.conn.db.Exec(fmt.Sprintf("SELECT 'agent(%d) end: token=%s'", agent.id, token))
+
+	if err != nil {
+		return fmt.Errorf("Failed to execute exit query (agent id=%d): %w", agent.id, err)
+	}
+
+	return nil
+}
+
+// Execute the next query after the previous query finishes
+func (agent *Agent) runClosedLoop(ctx context.Context, recorder *Recorder) error {
 	recordTick := time.NewTicker(RecordPeriod)
 	defer recordTick.Stop()
 	recDps := []recorderDataPoint{}
+	txDps := []recorderTxDataPoint{}
+	connDps := []recorderConnectDataPoint{}
+	runStart := time.Now()
 
-	err = loopWithThrottle(agent.taskOps.Rate, func(i int) (bool, error) {
+	err := loopWithThrottle(agent.taskOps.Rate, agent.taskOps.RateStages, func(i int) (bool, error) {
 		if agent.taskOps.NumberQueriesToExecute > 0 && i >= agent.taskOps.NumberQueriesToExecute {
 			return false, nil
 		}
@@ -80,58 +269,463 @@ func (agent *Agent) run(ctx context.Context, recorder *Recorder, token string) e
 		case <-ctx.Done():
 			return false, nil
 		case <-recordTick.C:
-			recorder.add(recDps)
-			recDps = recDps[:0]
+			agent.flush(recorder, recDps, time.Since(runStart))
+			recDps = []recorderDataPoint{}
+			recorder.addTransactions(txDps)
+			txDps = []recorderTxDataPoint{}
+			recorder.addConnects(connDps)
+			connDps = []recorderConnectDataPoint{}
 		default:
 			// Nothing to do
 		}
 
-		q := agent.data.next()
-		rt, err := agent.query(ctx, q)
+		if agent.needsReconnect(agent.conn, i) {
+			connDp, err := agent.reconnect(ctx, agent.conn, true)
 
-		if err != nil {
-			return false, fmt.Errorf("Execute query error (query=%s): %w", q, err)
+			// NOTE: The test finished while reconnecting
+			if ctx.Err() != nil {
+				return false, nil
+			}
+
+			connDps = append(connDps, connDp)
+
+			if agent.canReconnect(err) {
+				return agent.reconnectWithBackoff(ctx, agent.conn, recorder), nil
+			}
+
+			if err != nil {
+				err = agent.handleError(recorder, connectStmt, err)
+				return err == nil, err
+			}
 		}
 
-		recDps = append(recDps, recorderDataPoint{
-			timestamp: time.Now(),
-			resTime:   rt,
-		})
+		if agent.dataOpts.Transaction {
+			dps, txDp, stmt, err := agent.transact(ctx, agent.conn)
+			recDps = append(recDps, dps...)
+			txDps = append(txDps, txDp)
+
+			if agent.canReconnect(err) {
+				return agent.reconnectWithBackoff(ctx, agent.conn, recorder), nil
+			}
+
+			if err != nil {
+				// NOTE: Both the failed statement and ROLLBACK may fail
+				errCnt := 0
+
+				for _, dp := range dps {
+					if dp.err != nil {
+						errCnt++
+					}
+				}
+
+				if errCnt < 1 {
+					errCnt = 1
+				}
+
+				err = agent.handleErrors(recorder, stmt, err, errCnt)
+				return err == nil, err
+			}
+
+			return true, nil
+		}
+
+		stmt := agent.data.next()
+		dp, err := agent.query(ctx, agent.conn, stmt)
+		recDps = append(recDps, dp)
+
+		if agent.canReconnect(err) {
+			return agent.reconnectWithBackoff(ctx, agent.conn, recorder), nil
+		}
+
+		if err != nil {
+			err = agent.handleError(recorder, stmt, err)
+			return err == nil, err
+		}
 
 		return true, nil
 	})
 
-	if err != nil {
-		return fmt.Errorf("Failed to transact (agent id=%d): %w", agent.id, err)
+	recorder.add(recDps)
+	recorder.addTransactions(txDps)
+	recorder.addConnects(connDps)
+
+	return err
+}
+
+// Execute the custom queries as a single transaction, and retry it on deadlock.
+// If the transaction fails, returns the failed statement and the error.
+func (agent *Agent) transact(ctx context.Context, conn *agentConn) ([]recorderDataPoint, recorderTxDataPoint, *statement, error) {
+	stmts := agent.data.transaction()
+	recDps := []recorderDataPoint{}
+	txDp := recorderTxDataPoint{agentId: agent.id}
+	start := time.Now()
+	var failedStmt *statement
+	var err error
+
+	for {
+		failedStmt, err = nil, nil
+
+		failedIdx := -1
+
+		for _, stmt := range stmts {
+			dp, qErr := agent.query(ctx, conn, stmt)
+			recDps = append(recDps, dp)
+
+			if qErr != nil {
+				failedStmt, err = stmt, qErr
+				failedIdx = len(recDps) - 1
+				break
+			}
+		}
+
+		if err == nil {
+			break
+		}
+
+		dp, rbErr := agent.query(ctx, conn, rollbackStmt)
+		recDps = append(recDps, dp)
+		txDp.rollbackCnt++
+
+		if rbErr != nil {
+			failedStmt, err = rollbackStmt, rbErr
+			break
+		}
+
+		if !isDeadlock(err) || txDp.retryCnt >= agent.dataOpts.TransactionRetries {
+			break
+		}
+
+		// NOTE: The retried deadlock is counted as a retry of the transaction instead of an error
+		recDps = append(recDps[:failedIdx], recDps[failedIdx+1:]...)
+		txDp.retryCnt++
 	}
 
-	_, err = agent.db.Exec(fmt.Sprintf("SELECT 'agent(%d) end: token=%s'", agent.id, token))
+	end := time.Now()
+	txDp.timestamp = end
+	txDp.resTime = end.Sub(start)
+	txDp.err = err
 
-	if err != nil {
-		return fmt.Errorf("Failed to execute exit query (agent id=%d): %w", agent.id, err)
+	return recDps, txDp, failedStmt, err
+}
+
+// Statements executed in order on the same worker connection
+type openLoopJob struct {
+	stmts         []*statement
+	intendedStart time.Time
+}
+
+// Execute queries at fixed intended start times regardless of the response time
+func (agent *Agent) runOpenLoop(ctx context.Context, recorder *Recorder) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	// NOTE: Sending a job succeeds only if a worker is idle
+	jobs := make(chan openLoopJob)
+	dpCh := make(chan recorderDataPoint, len(agent.workerConns))
+	collectorDone := make(chan struct{})
+	runStart := time.Now()
+
+	// Collect data points from workers
+	go func() {
+		recordTick := time.NewTicker(RecordPeriod)
+		defer recordTick.Stop()
+		recDps := []recorderDataPoint{}
+
+	LOOP:
+		for {
+			select {
+			case dp, ok := <-dpCh:
+				if !ok {
+					break LOOP
+				}
+
+				recDps = append(recDps, dp)
+			case <-recordTick.C:
+				agent.flush(recorder, recDps, time.Since(runStart))
+				recDps = []recorderDataPoint{}
+			}
+		}
+
+		recorder.add(recDps)
+		close(collectorDone)
+	}()
+
+	startWorker := func(conn *agentConn) {
+		eg.Go(func() error {
+			i := 0
+
+			for job := range jobs {
+				if agent.needsReconnect(conn, i) {
+					connDp, err := agent.reconnect(ctx, conn, true)
+
+					// NOTE: The test finished while reconnecting
+					if ctx.Err() != nil {
+						return nil
+					}
+
+					recorder.addConnects([]recorderConnectDataPoint{connDp})
+
+					if agent.canReconnect(err) {
+						// NOTE: Execute the job after reconnecting not to lose the scheduled queries
+						if !agent.reconnectWithBackoff(ctx, conn, recorder) {
+							return nil
+						}
+					} else if err != nil {
+						// NOTE: Record the job as failed not to lose the scheduled queries
+						dpCh <- agent.newErrorDataPoint(conn, job.stmts[0], err)
+						err = agent.handleError(recorder, connectStmt, err)
+
+						if err != nil {
+							return err
+						}
+
+						continue
+					}
+				}
+
+				i++
+				ok, err := agent.runJob(ctx, conn, job, dpCh, recorder)
+
+				if !ok {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	for _, conn := range agent.workerConns {
+		startWorker(conn)
+	}
+
+	// Schedule queries
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	next := runStart
+	canGrow := true
+
+LOOP:
+	for i := 0; agent.taskOps.NumberQueriesToExecute <= 0 || i < agent.taskOps.NumberQueriesToExecute; {
+		rate := rateAt(agent.taskOps.Rate, agent.taskOps.RateStages, next.Sub(runStart))
+
+		if wait := time.Until(next); wait > 0 {
+			timer.Reset(wait)
+
+			select {
+			case <-ctx.Done():
+				break LOOP
+			case <-timer.C:
+				// Nothing to do
+			}
+		}
+
+		job := openLoopJob{stmts: agent.data.nextUnit(), intendedStart: next}
+
+		select {
+		case jobs <- job:
+			canGrow = true
+		default:
+			// NOTE: Add a worker when all workers are busy not to delay the following jobs.
+			// Stop adding workers after a failure until a worker becomes idle.
+			if canGrow && len(agent.workerConns) < agent.taskOps.OpenLoopMaxWorkers {
+				conn, err := agent.addWorkerConn(ctx)
+
+				if err == nil {
+					startWorker(conn)
+				} else if ctx.Err() == nil {
+					canGrow = false
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				break LOOP
+			case jobs <- job:
+				// Nothing to do
+			}
+		}
+
+		// NOTE: Each statement of the job takes its own interval to keep the rate
+		next = next.Add(time.Duration(len(job.stmts)) * time.Second / time.Duration(rate))
+		i += len(job.stmts)
+	}
+
+	close(jobs)
+	err := eg.Wait()
+	close(dpCh)
+	<-collectorDone
+
+	return err
+}
+
+// Execute the statements of the job until one fails.
+// Returns false if the worker should stop.
+func (agent *Agent) runJob(ctx context.Context, conn *agentConn, job openLoopJob, dpCh chan<- recorderDataPoint, recorder *Recorder) (bool, error) {
+	for i, stmt := range job.stmts {
+		// NOTE: The following statements are executed right after the previous one
+		var schedDelay time.Duration
+
+		if i == 0 {
+			schedDelay = time.Since(job.intendedStart)
+		}
+
+		dp, err := agent.query(ctx, conn, stmt)
+
+		if agent.canReconnect(err) {
+			dpCh <- dp
+			return agent.reconnectWithBackoff(ctx, conn, recorder), nil
+		}
+
+		if err != nil {
+			dpCh <- dp
+			err = agent.handleError(recorder, stmt, err)
+			return err == nil, err
+		}
+
+		dp.schedDelay = schedDelay
+		dpCh <- dp
+	}
+
+	return true, nil
+}
+
+func (agent *Agent) flush(recorder *Recorder, recDps []recorderDataPoint, elapsed time.Duration) {
+	recorder.add(recDps)
+
+	if recorder.metrics != nil {
+		rate := rateAt(agent.taskOps.Rate, agent.taskOps.RateStages, elapsed)
+		recorder.metrics.setTargetRate(agent.id, rate)
+	}
+}
+
+func (agent *Agent) newErrorDataPoint(conn *agentConn, stmt *statement, err error) recorderDataPoint {
+	return recorderDataPoint{
+		timestamp: time.Now(),
+		agentId:   agent.id,
+		workerId:  conn.workerId,
+		tag:       stmt.tag,
+		err:       err,
+		connLost:  isConnectionError(err),
+	}
+}
+
+func (agent *Agent) canReconnect(err error) bool {
+	return err != nil && agent.taskOps.AutoReconnect && isConnectionError(err)
+}
+
+// Reconnect with exponential backoff until it succeeds or the test finishes.
+// Returns false if the test finished before reconnecting.
+func (agent *Agent) reconnectWithBackoff(ctx context.Context, conn *agentConn, recorder *Recorder) bool {
+	backoff := ReconnectMinBackoff
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			// Nothing to do
+		}
+
+		connDp, err := agent.reconnect(ctx, conn, false)
+
+		// NOTE: The test finished while reconnecting
+		if ctx.Err() != nil {
+			return false
+		}
+
+		recorder.addConnects([]recorderConnectDataPoint{connDp})
+
+		if err == nil {
+			return true
+		}
+
+		timer.Reset(backoff)
+		backoff *= 2
+
+		if backoff > agent.taskOps.MaxReconnectBackoff {
+			backoff = agent.taskOps.MaxReconnectBackoff
+		}
+	}
+}
+
+// Returns an error if the agent should stop according to the error policy
+func (agent *Agent) handleError(recorder *Recorder, stmt *statement, err error) error {
+	return agent.handleErrors(recorder, stmt, err, 1)
+}
+
+// Handle n errors of one unit, e.g. a failed statement and ROLLBACK of a transaction
+func (agent *Agent) handleErrors(recorder *Recorder, stmt *statement, err error, n int) error {
+	if agent.taskOps.OnError != ErrorPolicyContinue {
+		return fmt.Errorf("Execute query error (query=%s): %w", stmt.sql, err)
+	}
+
+	// NOTE: Errors during warm-up are left out of the report, and so out of the limit
+	if recorder.inWarmUp() {
+		return nil
+	}
+
+	errCnt := recorder.countErrors(n)
+
+	if agent.taskOps.MaxErrors > 0 && errCnt > agent.taskOps.MaxErrors {
+		return fmt.Errorf("Too many errors (max=%d, last query=%s): %w", agent.taskOps.MaxErrors, stmt.sql, err)
 	}
 
 	return nil
 }
 
 func (agent *Agent) close() error {
-	err := agent.db.Close()
+	for _, conn := range append([]*agentConn{agent.conn}, agent.workerConns...) {
+		err := conn.close()
 
-	if err != nil {
-		return fmt.Errorf("Failed to close DB (agent id=%d): %w", agent.id, err)
+		if err != nil {
+			return fmt.Errorf("Failed to close DB (agent id=%d): %w", agent.id, err)
+		}
 	}
 
 	return nil
 }
 
-func (agent *Agent) query(ctx context.Context, q string) (time.Duration, error) {
+func (agent *Agent) query(ctx context.Context, conn *agentConn, stmt *statement) (recorderDataPoint, error) {
 	start := time.Now()
-	_, err := agent.db.ExecContext(ctx, q)
+	rowCnt, byteCnt, err := conn.exec(ctx, stmt)
 	end := time.Now()
 
-	if err != nil && !errors.Is(err, context.Canceled) {
-		return 0, err
+	// NOTE: The canceled query may close the connection, and the following queries fail
+	if err != nil && !errors.Is(err, context.Canceled) && ctx.Err() == nil {
+		return agent.newErrorDataPoint(conn, stmt, err), err
 	}
 
-	return end.Sub(start), nil
+	return recorderDataPoint{
+		timestamp: end,
+		agentId:   agent.id,
+		workerId:  conn.workerId,
+		tag:       stmt.tag,
+		resTime:   end.Sub(start),
+		rowCnt:    rowCnt,
+		byteCnt:   byteCnt,
+	}, nil
+}
+
+func isDeadlock(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == MysqlErrDeadlock
+}
+
+// Whether the connection is lost by server restart, failover, KILL, etc.
+func isConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, sql.ErrConnDone) {
+		return true
+	}
+
+	var netErr net.Error
+
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var myErr *mysql.MySQLError
+
+	return errors.As(err, &myErr) &&
+		(myErr.Number == MysqlErrServerShutdown || myErr.Number == MysqlErrServerGone || myErr.Number == MysqlErrServerLost)
 }
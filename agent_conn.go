@@ -0,0 +1,281 @@
+package qlap
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type agentConn struct {
+	db           DB
+	open         func(ctx context.Context) (DB, error) // Open a new DB to reconnect
+	closed       bool                                  // Closed by the failed reconnect
+	prepared     bool
+	pin          bool      // Execute all statements on a single connection
+	pinned       *sql.Conn // Connection taken from the DB when pinning
+	workerId     int       // Worker of the connection in open-loop mode
+	stmts        map[string]*sql.Stmt
+	connectCnt   int // Number of connections opened before counting reconnects
+	reconnectCnt int // Number of reconnects to the previous DBs
+	forcedCnt    int // Number of reconnects by the connection mode
+}
+
+// Executes statements on the DB or on the pinned connection
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func newAgentConn(db DB, prepared bool, pin bool) *agentConn {
+	return &agentConn{
+		db:       db,
+		prepared: prepared,
+		pin:      pin,
+		stmts:    map[string]*sql.Stmt{},
+	}
+}
+
+// Returns the number of rows and bytes read
+func (conn *agentConn) exec(ctx context.Context, stmt *statement) (int, int, error) {
+	if _, ok := conn.db.(*NullDB); ok {
+		_, err := conn.db.ExecContext(ctx, stmt.sql, stmt.args...)
+		return 0, 0, err
+	}
+
+	q, err := conn.queryer(ctx)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rowCnt, byteCnt, err := conn.execOn(ctx, q, stmt)
+
+	// NOTE: Take a new connection from the DB at the next statement
+	if conn.pinned != nil && isConnectionError(err) {
+		conn.unpin()
+	}
+
+	return rowCnt, byteCnt, err
+}
+
+func (conn *agentConn) execOn(ctx context.Context, q queryer, stmt *statement) (int, int, error) {
+	var ps *sql.Stmt
+
+	if conn.prepared && !stmt.noPrepare {
+		var err error
+		ps, err = conn.prepare(ctx, q, stmt.sql)
+
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if !stmt.returnsRows {
+		var res sql.Result
+		var err error
+
+		if ps != nil {
+			res, err = ps.ExecContext(ctx, stmt.args...)
+		} else {
+			res, err = q.ExecContext(ctx, stmt.sql, stmt.args...)
+		}
+
+		if err == nil && stmt.afterExec != nil {
+			stmt.afterExec(res)
+		}
+
+		return 0, 0, err
+	}
+
+	var rows *sql.Rows
+	var err error
+
+	if ps != nil {
+		rows, err = ps.QueryContext(ctx, stmt.args...)
+	} else {
+		rows, err = q.QueryContext(ctx, stmt.sql, stmt.args...)
+	}
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return drainRows(rows)
+}
+
+// Read all rows to include the transfer time in the response time
+func drainRows(rows *sql.Rows) (int, int, error) {
+	defer rows.Close()
+	cols, err := rows.Columns()
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	values := make([]sql.RawBytes, len(cols))
+	dest := make([]interface{}, len(cols))
+
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	rowCnt := 0
+	byteCnt := 0
+
+	for rows.Next() {
+		err = rows.Scan(dest...)
+
+		if err != nil {
+			return rowCnt, byteCnt, err
+		}
+
+		rowCnt++
+
+		for _, v := range values {
+			byteCnt += len(v)
+		}
+	}
+
+	return rowCnt, byteCnt, rows.Err()
+}
+
+// The DB may retry a statement on a new connection if the connection is lost,
+// so the statements of a transaction run on a connection pinned from the DB
+func (conn *agentConn) queryer(ctx context.Context) (queryer, error) {
+	db, ok := conn.db.(*countingDB)
+
+	if !conn.pin || !ok {
+		return conn.db, nil
+	}
+
+	if conn.pinned == nil {
+		pinned, err := db.Conn(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		conn.pinned = pinned
+	}
+
+	return conn.pinned, nil
+}
+
+// Release the pinned connection.
+// Prepared statements are discarded because they belong to the connection.
+func (conn *agentConn) unpin() {
+	conn.closeStmts()
+	_ = conn.pinned.Close()
+	conn.pinned = nil
+}
+
+func (conn *agentConn) closeStmts() {
+	for _, ps := range conn.stmts {
+		_ = ps.Close()
+	}
+
+	conn.stmts = map[string]*sql.Stmt{}
+}
+
+// Prepare each statement once and reuse it
+func (conn *agentConn) prepare(ctx context.Context, q queryer, query string) (*sql.Stmt, error) {
+	if ps, ok := conn.stmts[query]; ok {
+		return ps, nil
+	}
+
+	ps, err := q.PrepareContext(ctx, query)
+
+	if err != nil {
+		return nil, fmt.Errorf("Prepare error: %w", err)
+	}
+
+	conn.stmts[query] = ps
+
+	return ps, nil
+}
+
+// Start counting reconnects.
+// If no connection is open, the next one is not a reconnect.
+func (conn *agentConn) resetReconnects() {
+	db, ok := conn.db.(*countingDB)
+
+	if !ok {
+		return
+	}
+
+	conn.reconnectCnt = 0
+	conn.forcedCnt = 0
+	conn.connectCnt = db.connectCount()
+
+	if db.Stats().OpenConnections == 0 {
+		conn.connectCnt++
+	}
+}
+
+func (conn *agentConn) reconnects() int {
+	db, ok := conn.db.(*countingDB)
+
+	if !ok || conn.closed {
+		return conn.reconnectCnt
+	}
+
+	if n := db.connectCount() - conn.connectCnt; n > 0 {
+		return conn.reconnectCnt + n
+	}
+
+	return conn.reconnectCnt
+}
+
+// Close the DB and open a new one.
+// Prepared statements are discarded because they belong to the closed connection.
+// If forced is true, the reconnect is counted as the one by the connection mode instead of the lost connection.
+func (conn *agentConn) reconnect(ctx context.Context, forced bool) error {
+	if !conn.closed {
+		conn.reconnectCnt = conn.reconnects()
+
+		if conn.pinned != nil {
+			conn.unpin()
+		}
+
+		conn.closeStmts()
+		_ = conn.db.Close()
+		conn.closed = true
+	}
+
+	db, err := conn.open(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	conn.db = db
+	conn.closed = false
+	conn.connectCnt = 0
+
+	if forced {
+		conn.forcedCnt++
+	} else {
+		conn.reconnectCnt++
+	}
+
+	if db, ok := db.(*countingDB); ok {
+		conn.connectCnt = db.connectCount()
+	}
+
+	return nil
+}
+
+func (conn *agentConn) close() error {
+	if conn.closed {
+		return nil
+	}
+
+	if conn.pinned != nil {
+		conn.unpin()
+	}
+
+	conn.closeStmts()
+
+	return conn.db.Close()
+}
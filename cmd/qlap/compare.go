@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"qlap"
+	"strconv"
+	"strings"
+)
+
+type CompareFlags struct {
+	Baseline   string
+	Candidate  string
+	Thresholds map[string]float64
+}
+
+func parseThresholds(str string) (map[string]float64, error) {
+	thresholds := map[string]float64{}
+
+	if str == "" {
+		return thresholds, nil
+	}
+
+	for _, kv := range strings.Split(str, ",") {
+		parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid format: %s", kv)
+		}
+
+		name := strings.TrimSpace(parts[0])
+		known := false
+
+		for _, m := range qlap.CompareMetrics {
+			if name == m {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return nil, fmt.Errorf("unknown metric: %s", name)
+		}
+
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(parts[1]), "%"), 64)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if pct < 0 {
+			return nil, fmt.Errorf("threshold must be >= 0: %s", kv)
+		}
+
+		thresholds[name] = pct
+	}
+
+	return thresholds, nil
+}
+
+// Load a report of a single run or of phases
+func loadReports(path string) ([]*qlap.RecorderReport, error) {
+	rawReport, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	phasesReport := &qlap.PhasesReport{}
+	err = json.Unmarshal(rawReport, phasesReport)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(phasesReport.Phases) > 0 {
+		return phasesReport.Phases, nil
+	}
+
+	report := &qlap.RecorderReport{}
+	err = json.Unmarshal(rawReport, report)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return []*qlap.RecorderReport{report}, nil
+}
+
+// Compare phases with the same name, and return whether there is a regression
+func compareReports(w io.Writer, cf *CompareFlags) (bool, error) {
+	baseReports, err := loadReports(cf.Baseline)
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to load baseline report: %w", err)
+	}
+
+	candReports, err := loadReports(cf.Candidate)
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to load candidate report: %w", err)
+	}
+
+	candByPhase := map[string]*qlap.RecorderReport{}
+
+	for _, rr := range candReports {
+		candByPhase[rr.Phase] = rr
+	}
+
+	regressed := false
+
+	for _, base := range baseReports {
+		cand, ok := candByPhase[base.Phase]
+
+		if !ok {
+			return false, fmt.Errorf("Phase not found in candidate report: %s", base.Phase)
+		}
+
+		cmp := qlap.CompareReports(base, cand, cf.Thresholds)
+		printComparison(w, cmp)
+
+		if cmp.Regressed() {
+			regressed = true
+		}
+	}
+
+	return regressed, nil
+}
+
+func printComparison(w io.Writer, cmp *qlap.Comparison) {
+	if cmp.Phase != "" {
+		fmt.Fprintf(w, "[%s]\n", cmp.Phase)
+	}
+
+	fmt.Fprintf(w, "%-8s %14s %14s %10s %10s\n", "METRIC", "BASELINE", "CANDIDATE", "DELTA", "THRESHOLD")
+
+	for _, m := range cmp.Metrics {
+		format := "%14.3f"
+
+		if m.Name == qlap.CompareMetricQPS {
+			format = "%14.2f"
+		}
+
+		threshold := "-"
+
+		if m.Threshold > 0 {
+			threshold = strconv.FormatFloat(m.Threshold, 'f', -1, 64) + "%"
+		}
+
+		fmt.Fprintf(w, "%-8s "+format+" "+format+" %10s %10s", m.Name, m.Baseline, m.Candidate, formatDelta(m.Delta), threshold)
+
+		if m.Regression {
+			fmt.Fprint(w, "  REGRESSION")
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w)
+}
+
+func formatDelta(delta float64) string {
+	if math.IsInf(delta, 1) {
+		return "+Inf%"
+	}
+
+	return fmt.Sprintf("%+.2f%%", delta)
+}
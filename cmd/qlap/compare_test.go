@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadReports(t *testing.T) {
+	reports, err := loadReports("testdata/baseline.json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 1 || reports[0].Phase != "read" {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+
+	tm := reports[0].Response.Time
+
+	if tm.Avg != 1500*time.Microsecond || tm.P99 != 5*time.Millisecond || tm.Min != 500*time.Microsecond || tm.Cumulative != 1500*time.Millisecond {
+		t.Errorf("durations are not parsed: %+v", tm)
+	}
+
+	// A report of a single run
+	path := filepath.Join(t.TempDir(), "single.json")
+	err = ioutil.WriteFile(path, []byte(`{"AvgQPS": 10, "Response": {"Time": {"P99": "2.5ms"}}}`), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reports, err = loadReports(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 1 || reports[0].AvgQPS != 10 || reports[0].Response.Time.P99 != 2500*time.Microsecond {
+		t.Errorf("unexpected single report: %+v", reports)
+	}
+
+	err = ioutil.WriteFile(path, []byte(`{"Response": {"Time": {"P99": "2.5"}}}`), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadReports(path); err == nil {
+		t.Error("expected an error for a duration without a unit")
+	}
+}
+
+func TestCompareReports(t *testing.T) {
+	// The candidate has 5% less QPS, a 20% slower P99 and a 10% faster max
+	tests := []struct {
+		thresholds string
+		regressed  []string
+	}{
+		{"", nil},
+		{"qps=10,p99=25", nil},
+		{"p99=10", []string{"p99"}},
+		{"qps=3%,avg=5", []string{"qps", "avg"}},
+		{"max=1", nil},
+	}
+
+	for _, tt := range tests {
+		thresholds, err := parseThresholds(tt.thresholds)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		regressed, err := compareReports(&buf, &CompareFlags{
+			Baseline:   "testdata/baseline.json",
+			Candidate:  "testdata/candidate.json",
+			Thresholds: thresholds,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if regressed != (len(tt.regressed) > 0) {
+			t.Errorf("%q: regressed = %v, want %v", tt.thresholds, regressed, !regressed)
+		}
+
+		got := []string{}
+
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if strings.HasSuffix(line, "REGRESSION") {
+				got = append(got, strings.Fields(line)[0])
+			}
+		}
+
+		if strings.Join(got, ",") != strings.Join(tt.regressed, ",") {
+			t.Errorf("%q: regressed metrics = %v, want %v\n%s", tt.thresholds, got, tt.regressed, buf.String())
+		}
+	}
+}
+
+func TestCompareReportsPhaseNotFound(t *testing.T) {
+	_, err := compareReports(&bytes.Buffer{}, &CompareFlags{
+		Baseline:  "testdata/baseline.json",
+		Candidate: "testdata/missing.json",
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "Failed to load candidate report") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "candidate.json")
+	err = ioutil.WriteFile(path, []byte(`{"Phases": [{"Phase": "write"}]}`), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = compareReports(&bytes.Buffer{}, &CompareFlags{Baseline: "testdata/baseline.json", Candidate: path})
+
+	if err == nil || !strings.Contains(err.Error(), "Phase not found in candidate report: read") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -15,71 +15,224 @@ import (
 
 var version string
 
+// Bool flags set by '--flag=true' or '--flag=false'
+var boolFlagsSet = map[string]bool{}
+
 const (
 	DefaultTime                   = 60
 	DefaultDBName                 = "qlap"
 	DefaultNumberPrePopulatedData = 100
+	DefaultNumberTableKeys        = 10000
+	DefaultRangeSize              = 100
+	DefaultMixedSelInsRatio       = "1:1"
 	DefaultLoadType               = string(qlap.LoadTypeMixed)
 	DefaultNumberIntCols          = 1
 	DefaultNumberCharCols         = 1
 	DefaultDelimiter              = ";"
+	DefaultOnError                = string(qlap.ErrorPolicyAbort)
+	DefaultTimeSeriesFormat       = string(qlap.TimeSeriesFormatCSV)
+	DefaultOpenLoopWorkers        = 10
+	DefaultOpenLoopMaxWorkers     = 100
+	DefaultTransactionRetries     = 3
+	DefaultOutputFormat           = OutputFormatJSON
+	DefaultAgentDeviation         = 20
+	DefaultConnectionMode         = string(qlap.ConnectionModePersistent)
+	DefaultKeyDistribution        = string(qlap.KeyDistributionUniform)
+	DefaultMaxReconnectBackoff    = "5s"
 )
 
 type Flags struct {
 	qlap.TaskOpts
 	qlap.DataOpts
 	qlap.RecorderOpts
+	Phases        []*qlap.Phase
+	KneeThreshold float64
+	OutputFormat  string
+	Compare       *CompareFlags
+	Report        *ReportFlags
 }
 
 func parseFlags() (flags *Flags) {
 	flaggy.SetVersion(version)
 	flaggy.SetDescription("MySQL load testing tool like mysqlslap.")
 	flags = &Flags{}
-	var dsn string
-	flaggy.String(&dsn, "d", "dsn", "Data Source Name, see https://github.com/go-sql-driver/mysql#examples.")
-	flags.NAgents = 1
-	flaggy.Int(&flags.NAgents, "n", "nagents", "Number of agents.")
-	argTime := DefaultTime
-	flaggy.Int(&argTime, "t", "time", "Test run time (sec). Zero is infinity.")
-	flaggy.Int(&flags.NumberQueriesToExecute, "", "number-queries", "Number of queries to execute per agent. Zero is infinity.")
-	flaggy.Int(&flags.Rate, "r", "rate", "Rate limit for each agent (qps). Zero is unlimited.")
-	flaggy.Bool(&flags.AutoGenerateSql, "a", "auto-generate-sql", "Automatically generate SQL to execute.")
-	flaggy.Bool(&flags.GuidPrimary, "", "auto-generate-sql-guid-primary", "Use GUID as the primary key of the table to be created.")
-	var queries string
-	flaggy.String(&queries, "q", "query", "SQL to execute. (file or string)")
-	flags.NumberPrePopulatedData = DefaultNumberPrePopulatedData
-	flaggy.Int(&flags.NumberPrePopulatedData, "", "auto-generate-sql-write-number", "Number of rows to be pre-populated for each agent.")
-	strLoadType := DefaultLoadType
-	flaggy.String(&strLoadType, "l", "auto-generate-sql-load-type", "Test load type: 'mixed', 'update', 'write', 'key', or 'read'.")
-	flaggy.Int(&flags.NumberSecondaryIndexes, "", "auto-generate-sql-secondary-indexes", "Number of secondary indexes in the table to be created.")
-	flaggy.Int(&flags.CommitRate, "", "commit-rate", "Commit every X queries.")
-	mixedSelInsRatio := "1:1"
-	flaggy.String(&mixedSelInsRatio, "", "mixed-sel-ins-ratio", "Mixed load type 'SELECT:INSERT' ratio.")
-	flaggy.String(&flags.Engine, "e", "engine", "Engine of the table to be created.")
-	flags.NumberCharCols = DefaultNumberCharCols
-	flaggy.Int(&flags.NumberCharCols, "x", "number-char-cols", "Number of VARCHAR columns in the table to be created.")
-	flaggy.Bool(&flags.CharColsIndex, "", "char-cols-index", "Create indexes on VARCHAR columns in the table to be created.")
-	flags.NumberIntCols = DefaultNumberIntCols
-	flaggy.Int(&flags.NumberIntCols, "y", "number-int-cols", "Number of INT columns in the table to be created.")
-	flaggy.Bool(&flags.IntColsIndex, "", "int-cols-index", "Create indexes on INT columns in the table to be created.")
-	var preqs string
-	flaggy.String(&preqs, "", "pre-query", "Queries to be pre-executed for each agent.")
-	var creates string
-	flaggy.String(&creates, "", "create", "SQL for creating custom tables. (file or string)")
-	flaggy.Bool(&flags.DropExistingDatabase, "", "drop-db", "Forcibly delete the existing DB.")
-	flaggy.Bool(&flags.NoDropDatabase, "", "no-drop", "Do not drop database after testing.")
-	hinterval := "0"
-	flaggy.String(&hinterval, "", "hinterval", "Histogram interval, e.g. '100ms'.")
-	delimiter := DefaultDelimiter
-	flaggy.String(&delimiter, "F", "delimiter", "SQL statements delimiter.")
-	flaggy.Bool(&flags.OnlyPrint, "", "only-print", "Just print SQL without connecting to DB.")
-	flaggy.Bool(&flags.NoProgress, "", "no-progress", "Do not show progress.")
-	flaggy.Parse()
+	sc := newScenario()
+
+	if path := scenarioPath(os.Args[1:]); path != "" {
+		if err := sc.load(path); err != nil {
+			printErrorAndExit("Failed to load scenario: " + err.Error())
+		}
+	}
+
+	var scenario string
+	flaggy.String(&scenario, "", "scenario", "Scenario file (YAML or JSON). Flags override its values.")
+	flaggy.String(&sc.DSN, "d", "dsn", "Data Source Name, see https://github.com/go-sql-driver/mysql#examples.")
+	flaggy.String(&sc.NAgents, "n", "nagents", "Number of agents. Multiple values, e.g. '1,2,4,8', run a sweep across agent counts.")
+	flaggy.Int(&sc.Time, "t", "time", "Test run time (sec). Zero is infinity.")
+	flaggy.Int(&sc.NumberQueries, "", "number-queries", "Number of queries to execute per agent. Zero is infinity.")
+	flaggy.Int(&sc.Rate, "r", "rate", "Rate limit for each agent (qps). Zero is unlimited.")
+	flaggy.String(&sc.RateRamp, "", "rate-ramp", "Increase the rate of each agent linearly, e.g. '10:100:60s' (FROM:TO:DURATION).")
+	flaggy.String(&sc.RateSteps, "", "rate-steps", "Change the rate of each agent stepwise, e.g. '100:60s,200:60s' (RATE:DURATION,...). Rates must be >= 1.")
+	flaggy.Int(&sc.WarmUp, "", "warm-up", "Warm-up time (sec) excluded from the report.")
+	flaggy.Bool(&sc.OpenLoop, "", "open-loop", "Execute queries at fixed intended start times regardless of the response time.")
+	flaggy.Int(&sc.OpenLoopWorkers, "", "open-loop-workers", "Initial number of connections for each agent in open-loop mode.")
+	flaggy.Int(&sc.OpenLoopMaxWorkers, "", "open-loop-max-workers", "Maximum number of connections for each agent in open-loop mode. Connections are added while all of them are busy.")
+	flaggy.Bool(&sc.AutoGenerateSql, "a", "auto-generate-sql", "Automatically generate SQL to execute.")
+	flaggy.Bool(&sc.GuidPrimary, "", "auto-generate-sql-guid-primary", "Use GUID as the primary key of the table to be created.")
+	flaggy.String(&sc.Query, "q", "query", "SQL to execute. (file or string)")
+	flaggy.String(&sc.QueryWeights, "", "query-weights", "Weights to choose queries randomly, e.g. '80,15,5'.")
+	flaggy.String(&sc.QueryNames, "", "query-names", "Names of queries in the report, e.g. 'point,scan,write'.")
+	flaggy.Int(&sc.NumberPrePopulatedData, "", "auto-generate-sql-write-number", "Number of rows to be pre-populated for each agent.")
+	flaggy.String(&sc.LoadType, "l", "auto-generate-sql-load-type", "Test load type: 'mixed', 'update', 'write', 'key', 'read', 'delete', 'range', or 'upsert'.")
+	flaggy.Int(&sc.NumberSecondaryIndexes, "", "auto-generate-sql-secondary-indexes", "Number of secondary indexes in the table to be created.")
+	flaggy.Bool(&sc.PreparedStatement, "", "prepared-statement", "Use server-side prepared statements.")
+	flaggy.Int(&sc.CommitRate, "", "commit-rate", "Commit every X queries.")
+	flaggy.Bool(&sc.Transaction, "", "transaction", "Execute all '--query' statements in a transaction for each iteration.")
+	flaggy.Int(&sc.TransactionRetries, "", "transaction-retries", "Number of retries of a transaction on deadlock.")
+	flaggy.String(&sc.MixedSelInsRatio, "", "mixed-sel-ins-ratio", "Mixed load type 'SELECT:INSERT' ratio.")
+	flaggy.String(&sc.MixRatio, "", "mix-ratio", "Mixed load type ratio of any load types, e.g. 'key:10,update:2,range:1,delete:1'.")
+	flaggy.Int(&sc.RangeSize, "", "range-size", "Number of rows scanned by 'range' load type.")
+	flaggy.String(&sc.Engine, "e", "engine", "Engine of the table to be created.")
+	flaggy.Int(&sc.NumberCharCols, "x", "number-char-cols", "Number of VARCHAR columns in the table to be created.")
+	flaggy.Bool(&sc.CharColsIndex, "", "char-cols-index", "Create indexes on VARCHAR columns in the table to be created.")
+	flaggy.Int(&sc.NumberIntCols, "y", "number-int-cols", "Number of INT columns in the table to be created.")
+	flaggy.Bool(&sc.IntColsIndex, "", "int-cols-index", "Create indexes on INT columns in the table to be created.")
+	flaggy.String(&sc.Columns, "", "columns", "Columns of the table to be created instead of INT and VARCHAR, e.g. 'bigint:2:index,decimal(10,2),varchar(255):3,datetime:1:index,json(200),text(1000),enum(a,b,c)'.")
+	flaggy.String(&sc.KeyDistribution, "", "key-distribution", "Distribution of keys for load types except for 'write': 'uniform', 'zipfian[:THETA]', 'hotspot[:KEYS%:ACCESS%]', or 'latest[:THETA]'.")
+	flaggy.String(&sc.Table, "", "table", "Existing table to generate load against instead of creating one. The database must exist.")
+	flaggy.Int(&sc.NumberTableKeys, "", "table-keys", "Number of primary keys sampled from '--table' for load types except for 'write'.")
+	flaggy.String(&sc.PreQuery, "", "pre-query", "Queries to be pre-executed for each agent.")
+	flaggy.String(&sc.Create, "", "create", "SQL for creating custom tables. (file or string)")
+	flaggy.Bool(&sc.DropDB, "", "drop-db", "Forcibly delete the existing DB.")
+	flaggy.Bool(&sc.NoDrop, "", "no-drop", "Do not drop database after testing.")
+	flaggy.String(&sc.HInterval, "", "hinterval", "Histogram interval, e.g. '100ms'.")
+	flaggy.Int(&sc.HistogramPrecision, "", "histogram-precision", "Number of significant decimal digits of latencies (1-3).")
+	flaggy.String(&sc.Percentiles, "", "percentiles", "Additional percentiles of the response time, e.g. '90,99.99'.")
+	flaggy.Bool(&sc.AgentStats, "", "agent-stats", "Report statistics for each agent.")
+	flaggy.Float64(&sc.AgentDeviation, "", "agent-deviation", "Highlight agents whose QPS deviates from the mean by more than X%.")
+	flaggy.String(&sc.TimeSeries, "", "time-series", "File to write QPS and latency every second while testing.")
+	flaggy.String(&sc.TimeSeriesFormat, "", "time-series-format", "Time series file format: 'csv' or 'jsonl'.")
+	flaggy.String(&sc.MetricsAddr, "", "metrics-addr", "Address to expose Prometheus metrics while testing, e.g. ':9100'.")
+	flaggy.String(&sc.Samples, "", "samples", "File to write raw samples (CSV) to rebuild the report with the 'report' subcommand.")
+	flaggy.String(&sc.Delimiter, "F", "delimiter", "SQL statements delimiter.")
+	flaggy.String(&sc.OnError, "", "on-error", "Behavior on query error: 'abort' or 'continue'.")
+	flaggy.Int(&sc.MaxErrors, "", "max-errors", "Maximum number of query errors to continue, not counting errors during '--warm-up'. Zero is unlimited.")
+	flaggy.String(&sc.ConnectionMode, "", "connection-mode", "Connection handling: 'persistent', 'per-query', or 'per-transaction'.")
+	flaggy.Int(&sc.ReconnectEvery, "", "reconnect-every", "Reconnect every X queries in 'per-query' connection mode.")
+	flaggy.Bool(&sc.AutoReconnect, "", "auto-reconnect", "Reconnect with backoff when the connection is lost, and report outages.")
+	flaggy.String(&sc.MaxReconnectBackoff, "", "max-reconnect-backoff", "Maximum interval of reconnect attempts, e.g. '5s'.")
+	flaggy.Bool(&sc.OnlyPrint, "", "only-print", "Just print SQL without connecting to DB.")
+	flaggy.Bool(&sc.NoProgress, "", "no-progress", "Do not show progress.")
+	flaggy.String(&sc.OutputFormat, "o", "output-format", "Report format: 'json', 'text', 'markdown', or 'csv'.")
+	flaggy.Float64(&sc.KneeThreshold, "", "knee-threshold", "Mark the knee where QPS increases by less than X% from the previous phase.")
+	compareCmd := flaggy.NewSubcommand("compare")
+	compareCmd.Description = "Compare two saved reports and exit with status 1 on regression."
+	compare := &CompareFlags{}
+	compareCmd.AddPositionalValue(&compare.Baseline, "baseline", 1, true, "Baseline report file (JSON).")
+	compareCmd.AddPositionalValue(&compare.Candidate, "candidate", 2, true, "Candidate report file (JSON).")
+	var thresholds string
+	compareCmd.String(&thresholds, "", "threshold", "Allowed worsening in percent, e.g. 'qps=5,p99=10'. Metrics: qps, avg, p50, p75, p95, p99, p999, max.")
+	flaggy.AttachSubcommand(compareCmd, 1)
+	reportCmd := flaggy.NewSubcommand("report")
+	reportCmd.Description = "Rebuild the report from a samples file with other analysis settings."
+	report := &ReportFlags{}
+	reportCmd.AddPositionalValue(&report.Samples, "samples", 1, true, "Samples file written by '--samples'.")
+	flaggy.AttachSubcommand(reportCmd, 1)
+	flaggy.ParseArgs(splitFlagValues(os.Args[1:]))
 
 	if len(os.Args) <= 1 {
 		flaggy.ShowHelpAndExit("")
 	}
 
+	if compareCmd.Used {
+		var err error
+		compare.Thresholds, err = parseThresholds(thresholds)
+
+		if err != nil {
+			printErrorAndExit("Failed to parse thresholds: " + err.Error())
+		}
+
+		flags.Compare = compare
+		return
+	}
+
+	if reportCmd.Used {
+		parseAnalysisFlags(flags, sc)
+		flags.Report = report
+		return
+	}
+
+	flags.NumberQueriesToExecute = sc.NumberQueries
+	flags.Rate = sc.Rate
+	flags.OpenLoop = sc.OpenLoop
+	flags.OpenLoopWorkers = sc.OpenLoopWorkers
+	flags.OpenLoopMaxWorkers = sc.OpenLoopMaxWorkers
+	flags.AutoGenerateSql = sc.AutoGenerateSql
+	flags.GuidPrimary = sc.GuidPrimary
+	flags.NumberPrePopulatedData = sc.NumberPrePopulatedData
+	flags.NumberSecondaryIndexes = sc.NumberSecondaryIndexes
+	flags.PreparedStatement = sc.PreparedStatement
+	flags.CommitRate = sc.CommitRate
+	flags.Transaction = sc.Transaction
+	flags.TransactionRetries = sc.TransactionRetries
+	flags.Engine = sc.Engine
+	flags.NumberCharCols = sc.NumberCharCols
+	flags.CharColsIndex = sc.CharColsIndex
+	flags.NumberIntCols = sc.NumberIntCols
+	flags.IntColsIndex = sc.IntColsIndex
+	flags.Table = sc.Table
+	flags.NumberTableKeys = sc.NumberTableKeys
+	flags.DropExistingDatabase = sc.DropDB
+	flags.NoDropDatabase = sc.NoDrop
+	flags.TimeSeries = sc.TimeSeries
+	flags.MetricsAddr = sc.MetricsAddr
+	flags.Samples = sc.Samples
+	flags.MaxErrors = sc.MaxErrors
+	flags.ReconnectEvery = sc.ReconnectEvery
+	flags.AutoReconnect = sc.AutoReconnect
+	flags.OnlyPrint = sc.OnlyPrint
+	flags.NoProgress = sc.NoProgress
+
+	dsn := sc.DSN
+	strNAgents := sc.NAgents
+	argTime := sc.Time
+	rateRamp := sc.RateRamp
+	rateSteps := sc.RateSteps
+	warmUp := sc.WarmUp
+	queries := sc.Query
+	queryWeights := sc.QueryWeights
+	queryNames := sc.QueryNames
+	strLoadType := sc.LoadType
+	mixedSelInsRatio := sc.MixedSelInsRatio
+	preqs := sc.PreQuery
+	creates := sc.Create
+	strTimeSeriesFormat := sc.TimeSeriesFormat
+	delimiter := sc.Delimiter
+	strOnError := sc.OnError
+	strConnectionMode := sc.ConnectionMode
+
+	// Rate flags override all rate settings in the scenario file
+	if flagUsed("rate") || flagUsed("rate-ramp") || flagUsed("rate-steps") {
+		if !flagUsed("rate") {
+			flags.Rate = 0
+		}
+
+		if !flagUsed("rate-ramp") {
+			rateRamp = ""
+		}
+
+		if !flagUsed("rate-steps") {
+			rateSteps = ""
+		}
+
+		sc.RateStages = nil
+	}
+
+	// '--query(-q)' overrides the queries in the scenario file
+	if queries != "" {
+		sc.Queries = nil
+	}
+
 	// DSN
 	if dsn == "" {
 		printErrorAndExit("'--dsn(-d)' is required")
@@ -103,8 +256,16 @@ func parseFlags() (flags *Flags) {
 	}
 
 	// NAgents
-	if flags.NAgents < 1 {
-		printErrorAndExit("'--nagents(-n)' must be >= 1")
+	sweep, err := parseNAgents(strNAgents)
+
+	if err != nil {
+		printErrorAndExit("Failed to parse '--nagents(-n)': " + err.Error())
+	}
+
+	flags.NAgents = sweep[0]
+
+	if len(sweep) > 1 && len(sc.Phases) > 0 {
+		printErrorAndExit("Cannot set both multiple '--nagents(-n)' and phases")
 	}
 
 	// NumberQueriesToExecute
@@ -124,15 +285,99 @@ func parseFlags() (flags *Flags) {
 		printErrorAndExit("'--rate(-r)' must be >= 0")
 	}
 
+	// OnError
+	onError := qlap.ErrorPolicy(strOnError)
+
+	if onError != qlap.ErrorPolicyAbort && onError != qlap.ErrorPolicyContinue {
+		printErrorAndExit("Invalid error policy: " + strOnError)
+	}
+
+	flags.OnError = onError
+
+	// MaxErrors
+	if flags.MaxErrors < 0 {
+		printErrorAndExit("'--max-errors' must be >= 0")
+	}
+
+	if flags.MaxErrors > 0 && flags.OnError != qlap.ErrorPolicyContinue {
+		printErrorAndExit("'--max-errors' requires '--on-error continue'")
+	}
+
+	// RateStages
+	if rateRamp != "" && rateSteps != "" {
+		printErrorAndExit("Cannot set both '--rate-ramp' and '--rate-steps'")
+	}
+
+	if (rateRamp != "" || rateSteps != "") && flags.Rate != 0 {
+		printErrorAndExit("Cannot set both '--rate(-r)' and '--rate-ramp'/'--rate-steps'")
+	}
+
+	if rateRamp != "" {
+		stage, err := parseRateRamp(rateRamp)
+
+		if err != nil {
+			printErrorAndExit("Failed to parse rate ramp: " + err.Error())
+		}
+
+		flags.RateStages = []qlap.RateStage{*stage}
+	}
+
+	if rateSteps != "" {
+		flags.RateStages, err = parseRateSteps(rateSteps)
+
+		if err != nil {
+			printErrorAndExit("Failed to parse rate steps: " + err.Error())
+		}
+	}
+
+	// '--rate(-r)', '--rate-ramp' and '--rate-steps' override the rate stages in the scenario file
+	if flags.Rate == 0 && rateRamp == "" && rateSteps == "" {
+		for i, sst := range sc.RateStages {
+			stage, err := sst.rateStage()
+
+			if err != nil {
+				printErrorAndExit(fmt.Sprintf("Invalid rate stage #%d: %s", i, err))
+			}
+
+			flags.RateStages = append(flags.RateStages, *stage)
+		}
+	}
+
+	// WarmUp
+	if warmUp < 0 {
+		printErrorAndExit("'--warm-up' must be >= 0")
+	}
+
+	flags.WarmUp = time.Duration(warmUp) * time.Second
+
+	if flags.Time > 0 && flags.WarmUp >= flags.Time {
+		printErrorAndExit("'--warm-up' must be < '--time(-t)'")
+	}
+
+	// OpenLoop
+	if flags.OpenLoop && flags.Rate == 0 && len(flags.RateStages) == 0 {
+		printErrorAndExit("'--open-loop' requires '--rate(-r)', '--rate-ramp' or '--rate-steps'")
+	}
+
+	// OpenLoopWorkers
+	if flags.OpenLoopWorkers < 1 {
+		printErrorAndExit("'--open-loop-workers' must be >= 1")
+	}
+
+	// OpenLoopMaxWorkers
+	if flags.OpenLoopMaxWorkers < flags.OpenLoopWorkers {
+		printErrorAndExit("'--open-loop-max-workers' must be >= '--open-loop-workers'")
+	}
+
 	// Delimiter
 	if delimiter == "" {
 		printErrorAndExit("'--delimiter(-F)' must not be empty")
 	}
 
 	// AutoGenerateSql / Queries
-	if !flags.AutoGenerateSql && queries == "" {
+	if !flags.AutoGenerateSql && queries == "" && len(sc.Queries) == 0 {
 		printErrorAndExit("Either '--auto-generate-sql(-a)' or '--query(-q)' is required")
-	} else if flags.AutoGenerateSql && queries != "" {
+	} else if flags.AutoGenerateSql && (queries != "" || len(sc.Queries) > 0) {
 		printErrorAndExit("Cannot set both '--auto-generate-sql(-a)' and '--query(-q)'")
 	}
 
@@ -149,11 +394,75 @@ func parseFlags() (flags *Flags) {
 		}
 
 		flags.Queries = filterEmptyQuery(strings.Split(queries, delimiter))
+	} else if len(sc.Queries) > 0 {
+		for i, sq := range sc.Queries {
+			q := strings.TrimSpace(sq.SQL)
+
+			if q == "" {
+				printErrorAndExit(fmt.Sprintf("'sql' of query #%d must not be empty", i))
+			}
+
+			flags.Queries = append(flags.Queries, q)
+		}
+	}
+
+	// QueryWeights
+	if queryWeights != "" {
+		if len(flags.Queries) == 0 {
+			printErrorAndExit("'--query(-q)' is required for '--query-weights'")
+		}
+
+		flags.QueryWeights, err = parseQueryWeights(queryWeights, len(flags.Queries))
+
+		if err != nil {
+			printErrorAndExit("Failed to parse query weights: " + err.Error())
+		}
+	}
+
+	if queryWeights == "" && sc.hasQueryWeights() {
+		weights := make([]int, len(sc.Queries))
+
+		for i := range sc.Queries {
+			weights[i] = sc.Queries[i].weight()
+		}
+
+		flags.QueryWeights, err = checkQueryWeights(weights, len(flags.Queries))
+
+		if err != nil {
+			printErrorAndExit("Invalid query weights: " + err.Error())
+		}
+	}
+
+	// QueryNames
+	if queryNames != "" {
+		if len(flags.Queries) == 0 {
+			printErrorAndExit("'--query(-q)' is required for '--query-names'")
+		}
+
+		flags.QueryNames, err = parseQueryNames(queryNames, len(flags.Queries))
+
+		if err != nil {
+			printErrorAndExit("Failed to parse query names: " + err.Error())
+		}
+	}
+
+	if queryNames == "" && sc.hasQueryNames() {
+		names := make([]string, len(sc.Queries))
+
+		for i := range sc.Queries {
+			names[i] = sc.Queries[i].Name
+		}
+
+		flags.QueryNames, err = checkQueryNames(names, len(flags.Queries))
+
+		if err != nil {
+			printErrorAndExit("Invalid query names: " + err.Error())
+		}
 	}
 
 	// Creates
 	if creates != "" {
-		if queries == "" {
+		if len(flags.Queries) == 0 {
 			printErrorAndExit("'--query(-q)' is required for '--create'")
 		}
 
@@ -170,6 +479,30 @@ func parseFlags() (flags *Flags) {
 		flags.Creates = filterEmptyQuery(strings.Split(creates, delimiter))
 	}
 
+	// Transaction
+	if flags.Transaction {
+		if len(flags.Queries) == 0 {
+			printErrorAndExit("'--query(-q)' is required for '--transaction'")
+		}
+
+		if flags.CommitRate > 0 {
+			printErrorAndExit("Cannot set both '--transaction' and '--commit-rate'")
+		}
+
+		if len(flags.QueryWeights) > 0 {
+			printErrorAndExit("Cannot set both '--transaction' and '--query-weights'")
+		}
+
+		if flags.OpenLoop {
+			printErrorAndExit("Cannot set both '--transaction' and '--open-loop'")
+		}
+	}
+
+	// TransactionRetries
+	if flags.TransactionRetries < 0 {
+		printErrorAndExit("'--transaction-retries' must be >= 0")
+	}
+
 	// NumberPrePopulatedData
 	if flags.NumberPrePopulatedData < 0 {
 		printErrorAndExit("'--auto-generate-sql-write-number' must be >= 0")
@@ -178,19 +511,12 @@ func parseFlags() (flags *Flags) {
 	// LoadType
 	loadType := qlap.AutoGenerateSqlLoadType(strLoadType)
 
-	if loadType != qlap.LoadTypeMixed &&
-		loadType != qlap.LoadTypeUpdate &&
-		loadType != qlap.LoadTypeWrite &&
-		loadType != qlap.LoadTypeKey &&
-		loadType != qlap.LoadTypeRead {
+	if !isValidLoadType(loadType) {
 		printErrorAndExit("Invalid load type: " + strLoadType)
 	}
 
-	if flags.NumberPrePopulatedData == 0 && (loadType == qlap.LoadTypeMixed ||
-		loadType == qlap.LoadTypeUpdate ||
-		loadType == qlap.LoadTypeKey ||
-		loadType == qlap.LoadTypeRead) {
-		printErrorAndExit("Pre-populated data is required for 'mixed', 'update', 'key', and 'read'")
+	if flags.Table == "" && flags.NumberPrePopulatedData == 0 && requiresPrePopulatedData(loadType) {
+		printErrorAndExit("Pre-populated data is required for 'mixed', 'update', 'key', 'read', 'delete', 'range', and 'upsert'")
 	}
 
 	flags.LoadType = loadType
@@ -205,6 +531,47 @@ func parseFlags() (flags *Flags) {
 		printErrorAndExit("'--commit-rate' must be >= 0")
 	}
 
+	if flags.CommitRate > 0 && flags.OpenLoop {
+		printErrorAndExit("Cannot set both '--commit-rate' and '--open-loop'")
+	}
+
+	// ConnectionMode
+	connectionMode := qlap.ConnectionMode(strConnectionMode)
+
+	if connectionMode != qlap.ConnectionModePersistent &&
+		connectionMode != qlap.ConnectionModePerQuery &&
+		connectionMode != qlap.ConnectionModePerTransaction {
+		printErrorAndExit("Invalid connection mode: " + strConnectionMode)
+	}
+
+	if connectionMode == qlap.ConnectionModePerTransaction && !flags.Transaction {
+		printErrorAndExit("'--connection-mode per-transaction' requires '--transaction'")
+	}
+
+	if connectionMode == qlap.ConnectionModePerQuery && (flags.Transaction || flags.CommitRate > 0) {
+		printErrorAndExit("Cannot set both '--connection-mode per-query' and '--transaction' or '--commit-rate'")
+	}
+
+	flags.ConnectionMode = connectionMode
+
+	// ReconnectEvery
+	if flags.ReconnectEvery < 1 {
+		printErrorAndExit("'--reconnect-every' must be >= 1")
+	}
+
+	if flags.ReconnectEvery > 1 && flags.ConnectionMode != qlap.ConnectionModePerQuery {
+		printErrorAndExit("'--reconnect-every' requires '--connection-mode per-query'")
+	}
+
+	// MaxReconnectBackoff
+	if backoff, err := time.ParseDuration(sc.MaxReconnectBackoff); err != nil {
+		printErrorAndExit("Failed to parse max reconnect backoff: " + err.Error())
+	} else if backoff < qlap.ReconnectMinBackoff {
+		printErrorAndExit(fmt.Sprintf("'--max-reconnect-backoff' must be >= %s", qlap.ReconnectMinBackoff))
+	} else {
+		flags.MaxReconnectBackoff = backoff
+	}
+
 	// MixedSelRatio / MixedInsRatio
 	if !strings.Contains(mixedSelInsRatio, ":") {
 		printErrorAndExit("Invalid mixed type 'SELECT:INSERT' ratio: ':' is not included")
@@ -231,6 +598,30 @@ func parseFlags() (flags *Flags) {
 		printErrorAndExit("Mixed type INSERT ratio must be >= 1")
 	}
 
+	// MixRatios
+	if sc.MixRatio != "" {
+		if mixedSelInsRatio != DefaultMixedSelInsRatio {
+			printErrorAndExit("Cannot set both '--mix-ratio' and '--mixed-sel-ins-ratio'")
+		}
+
+		if flags.LoadType != qlap.LoadTypeMixed {
+			printErrorAndExit("'--mix-ratio' requires '--auto-generate-sql-load-type(-l) mixed'")
+		}
+
+		flags.MixRatios, err = parseMixRatio(sc.MixRatio)
+
+		if err != nil {
+			printErrorAndExit("Failed to parse mix ratio: " + err.Error())
+		}
+	}
+
+	// RangeSize
+	flags.RangeSize = sc.RangeSize
+
+	if flags.RangeSize < 1 {
+		printErrorAndExit("'--range-size' must be >= 1")
+	}
+
 	// NumberIntCols
 	if flags.NumberIntCols < 1 {
 		printErrorAndExit("'--number-int-cols(-y)' must be >= 1")
@@ -241,19 +632,249 @@ func parseFlags() (flags *Flags) {
 		printErrorAndExit("'--number-char-cols(-x)' must be >= 1")
 	}
 
+	// Columns
+	if sc.Columns != "" {
+		for name, flag := range map[string]string{
+			"number-char-cols": "--number-char-cols(-x)",
+			"char-cols-index":  "--char-cols-index",
+			"number-int-cols":  "--number-int-cols(-y)",
+			"int-cols-index":   "--int-cols-index",
+		} {
+			if sc.has(name) {
+				printErrorAndExit(fmt.Sprintf("Cannot set both '--columns' and '%s'", flag))
+			}
+		}
+
+		columns, err := parseColumns(sc.Columns)
+
+		if err != nil {
+			printErrorAndExit("Failed to parse columns: " + err.Error())
+		}
+
+		flags.Columns = columns
+	}
+
+	// KeyDistribution
+	if err := parseKeyDistribution(sc.KeyDistribution, &flags.DataOpts); err != nil {
+		printErrorAndExit("Failed to parse key distribution: " + err.Error())
+	}
+
+	if flags.KeyDistribution != qlap.KeyDistributionUniform {
+		if !flags.AutoGenerateSql {
+			printErrorAndExit("'--auto-generate-sql(-a)' is required for '--key-distribution'")
+		}
+
+		if flags.KeyDistribution == qlap.KeyDistributionLatest && flags.GuidPrimary {
+			printErrorAndExit("Cannot set both '--key-distribution latest' and '--auto-generate-sql-guid-primary'")
+		}
+	}
+
+	// Table
+	if flags.Table != "" {
+		if !flags.AutoGenerateSql {
+			printErrorAndExit("'--auto-generate-sql(-a)' is required for '--table'")
+		}
+
+		if sc.Columns != "" {
+			printErrorAndExit("Cannot set both '--table' and '--columns'")
+		}
+
+		if flags.DropExistingDatabase {
+			printErrorAndExit("Cannot set both '--table' and '--drop-db'")
+		}
+
+		if flags.OnlyPrint {
+			printErrorAndExit("Cannot set both '--table' and '--only-print'")
+		}
+	}
+
+	// NumberTableKeys
+	if flags.NumberTableKeys < 1 {
+		printErrorAndExit("'--table-keys' must be >= 1")
+	}
+
 	// PreQueries
 	if preqs != "" {
 		flags.PreQueries = strings.Split(preqs, delimiter)
 	}
 
+	// HInterval, HistogramPrecision, Percentiles, AgentStats, OutputFormat, KneeThreshold
+	parseAnalysisFlags(flags, sc)
+
+	// TimeSeriesFormat
+	timeSeriesFormat := qlap.TimeSeriesFormat(strTimeSeriesFormat)
+
+	if timeSeriesFormat != qlap.TimeSeriesFormatCSV && timeSeriesFormat != qlap.TimeSeriesFormatJSONL {
+		printErrorAndExit("Invalid time series format: " + strTimeSeriesFormat)
+	}
+
+	flags.TimeSeriesFormat = timeSeriesFormat
+
+	// Phases
+	maxAgents := flags.NAgents
+	names := map[string]bool{}
+
+	for i := range sc.Phases {
+		phase, err := sc.Phases[i].phase(flags)
+
+		if err != nil {
+			printErrorAndExit(fmt.Sprintf("Invalid phase #%d: %s", i, err))
+		}
+
+		if names[phase.Name] {
+			printErrorAndExit("Duplicate phase name: " + phase.Name)
+		}
+
+		names[phase.Name] = true
+		flags.Phases = append(flags.Phases, phase)
+
+		if phase.NAgents > maxAgents {
+			maxAgents = phase.NAgents
+		}
+	}
+
+	// Sweep
+	if len(sweep) > 1 {
+		for _, n := range sweep {
+			phase := &qlap.Phase{
+				Name:                   fmt.Sprintf("nagents=%d", n),
+				NAgents:                n,
+				Time:                   flags.Time,
+				NumberQueriesToExecute: flags.NumberQueriesToExecute,
+				Rate:                   flags.Rate,
+				RateStages:             flags.RateStages,
+				WarmUp:                 flags.WarmUp,
+				LoadType:               flags.LoadType,
+			}
+
+			flags.Phases = append(flags.Phases, phase)
+
+			if n > maxAgents {
+				maxAgents = n
+			}
+		}
+	}
+
+	// KneeThreshold
+	if flags.KneeThreshold > 0 && len(flags.Phases) == 0 {
+		printErrorAndExit("'--knee-threshold' requires multiple '--nagents(-n)' or phases")
+	}
+
+	// NOTE: Prepare agents for the phase with the most agents
+	flags.NAgents = maxAgents
+
+	return
+}
+
+// Flags to analyze the report, shared with the 'report' subcommand
+func parseAnalysisFlags(flags *Flags, sc *Scenario) {
 	// HInterval
-	if hi, err := time.ParseDuration(hinterval); err != nil {
+	if hi, err := time.ParseDuration(sc.HInterval); err != nil {
 		printErrorAndExit("Failed to parse hinterval: " + err.Error())
 	} else {
 		flags.HInterval = hi
 	}
 
-	return
+	// HistogramPrecision
+	flags.HistogramPrecision = sc.HistogramPrecision
+
+	if flags.HistogramPrecision < 1 || flags.HistogramPrecision > qlap.MaxHistogramPrecision {
+		printErrorAndExit(fmt.Sprintf("'--histogram-precision' must be between 1 and %d", qlap.MaxHistogramPrecision))
+	}
+
+	// Percentiles
+	if sc.Percentiles != "" {
+		percentiles, err := parsePercentiles(sc.Percentiles)
+
+		if err != nil {
+			printErrorAndExit("Failed to parse percentiles: " + err.Error())
+		}
+
+		flags.Percentiles = percentiles
+	}
+
+	// AgentStats
+	flags.AgentStats = sc.AgentStats
+	flags.AgentDeviation = sc.AgentDeviation
+
+	if flags.AgentDeviation < 0 {
+		printErrorAndExit("'--agent-deviation' must be >= 0")
+	}
+
+	// OutputFormat
+	flags.OutputFormat = sc.OutputFormat
+
+	if !isValidOutputFormat(flags.OutputFormat) {
+		printErrorAndExit("Invalid output format: " + flags.OutputFormat)
+	}
+
+	// KneeThreshold
+	flags.KneeThreshold = sc.KneeThreshold
+
+	if flags.KneeThreshold < 0 {
+		printErrorAndExit("'--knee-threshold' must be >= 0")
+	}
+}
+
+func parsePercentiles(str string) ([]float64, error) {
+	percentiles := []float64{}
+
+	for _, s := range strings.Split(str, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if p <= 0 || p > 100 {
+			return nil, fmt.Errorf("percentile must be > 0 and <= 100: %s", s)
+		}
+
+		percentiles = append(percentiles, p)
+	}
+
+	return percentiles, nil
+}
+
+func parseNAgents(str string) ([]int, error) {
+	sweep := []int{}
+
+	for _, s := range strings.Split(str, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if n < 1 {
+			return nil, fmt.Errorf("must be >= 1: %d", n)
+		}
+
+		sweep = append(sweep, n)
+	}
+
+	return sweep, nil
+}
+
+func isValidLoadType(loadType qlap.AutoGenerateSqlLoadType) bool {
+	return loadType == qlap.LoadTypeMixed ||
+		loadType == qlap.LoadTypeUpdate ||
+		loadType == qlap.LoadTypeWrite ||
+		loadType == qlap.LoadTypeKey ||
+		loadType == qlap.LoadTypeRead ||
+		loadType == qlap.LoadTypeDelete ||
+		loadType == qlap.LoadTypeRange ||
+		loadType == qlap.LoadTypeUpsert
+}
+
+func requiresPrePopulatedData(loadType qlap.AutoGenerateSqlLoadType) bool {
+	return loadType == qlap.LoadTypeMixed ||
+		loadType == qlap.LoadTypeUpdate ||
+		loadType == qlap.LoadTypeKey ||
+		loadType == qlap.LoadTypeRead ||
+		loadType == qlap.LoadTypeDelete ||
+		loadType == qlap.LoadTypeRange ||
+		loadType == qlap.LoadTypeUpsert
 }
 
 func printErrorAndExit(msg string) {
@@ -261,6 +882,391 @@ func printErrorAndExit(msg string) {
 	os.Exit(1)
 }
 
+func parseQueryWeights(str string, numQueries int) ([]int, error) {
+	parts := strings.Split(str, ",")
+	weights := make([]int, len(parts))
+
+	for i, p := range parts {
+		w, err := strconv.Atoi(strings.TrimSpace(p))
+
+		if err != nil {
+			return nil, err
+		}
+
+		weights[i] = w
+	}
+
+	return checkQueryWeights(weights, numQueries)
+}
+
+func checkQueryWeights(weights []int, numQueries int) ([]int, error) {
+	if len(weights) != numQueries {
+		return nil, fmt.Errorf("number of weights (%d) does not match number of queries (%d)", len(weights), numQueries)
+	}
+
+	sum := 0
+
+	for _, w := range weights {
+		if w < 0 {
+			return nil, fmt.Errorf("weight must be >= 0: %d", w)
+		}
+
+		sum += w
+	}
+
+	if sum < 1 {
+		return nil, fmt.Errorf("sum of weights must be >= 1")
+	}
+
+	return weights, nil
+}
+
+func parseQueryNames(str string, numQueries int) ([]string, error) {
+	return checkQueryNames(strings.Split(str, ","), numQueries)
+}
+
+func checkQueryNames(names []string, numQueries int) ([]string, error) {
+	if len(names) != numQueries {
+		return nil, fmt.Errorf("number of names (%d) does not match number of queries (%d)", len(names), numQueries)
+	}
+
+	seen := map[string]bool{}
+
+	for i, name := range names {
+		name = strings.TrimSpace(name)
+
+		if name == "" {
+			return nil, fmt.Errorf("name must not be empty")
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate name: %s", name)
+		}
+
+		for _, tag := range qlap.StatementTags {
+			if strings.EqualFold(name, tag) {
+				return nil, fmt.Errorf("reserved name: %s", name)
+			}
+		}
+
+		seen[name] = true
+		names[i] = name
+	}
+
+	return names, nil
+}
+
+func parseRateRamp(str string) (*qlap.RateStage, error) {
+	parts := strings.Split(str, ":")
+
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid format: %s", str)
+	}
+
+	from, err := strconv.Atoi(parts[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := strconv.Atoi(parts[1])
+
+	if err != nil {
+		return nil, err
+	}
+
+	if from < 1 || to < 1 {
+		return nil, fmt.Errorf("rate must be >= 1: %s", str)
+	}
+
+	d, err := time.ParseDuration(parts[2])
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d <= 0 {
+		return nil, fmt.Errorf("duration must be > 0: %s", str)
+	}
+
+	return &qlap.RateStage{FromRate: from, ToRate: to, Duration: d}, nil
+}
+
+func parseRateSteps(str string) ([]qlap.RateStage, error) {
+	stages := []qlap.RateStage{}
+
+	for _, step := range strings.Split(str, ",") {
+		parts := strings.Split(strings.TrimSpace(step), ":")
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid format: %s", step)
+		}
+
+		rate, err := strconv.Atoi(parts[0])
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rate < 1 {
+			return nil, fmt.Errorf("rate must be >= 1: %s", step)
+		}
+
+		d, err := time.ParseDuration(parts[1])
+
+		if err != nil {
+			return nil, err
+		}
+
+		if d <= 0 {
+			return nil, fmt.Errorf("duration must be > 0: %s", step)
+		}
+
+		stages = append(stages, qlap.RateStage{FromRate: rate, ToRate: rate, Duration: d})
+	}
+
+	return stages, nil
+}
+
+// e.g. "key:10,update:2,write:1" (LOAD_TYPE:RATIO,...)
+func parseMixRatio(str string) ([]qlap.LoadTypeRatio, error) {
+	ratios := []qlap.LoadTypeRatio{}
+
+	for _, s := range strings.Split(str, ",") {
+		kv := strings.SplitN(strings.TrimSpace(s), ":", 2)
+
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid ratio: %s", s)
+		}
+
+		loadType := qlap.AutoGenerateSqlLoadType(kv[0])
+
+		if !isValidLoadType(loadType) || loadType == qlap.LoadTypeMixed {
+			return nil, fmt.Errorf("invalid load type: %s", kv[0])
+		}
+
+		ratio, err := strconv.Atoi(kv[1])
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ratio < 1 {
+			return nil, fmt.Errorf("ratio must be >= 1: %s", s)
+		}
+
+		ratios = append(ratios, qlap.LoadTypeRatio{LoadType: loadType, Ratio: ratio})
+	}
+
+	return ratios, nil
+}
+
+// e.g. "zipfian:0.99", "hotspot:20:80" (DISTRIBUTION[:PARAMS])
+func parseKeyDistribution(str string, opts *qlap.DataOpts) error {
+	parts := strings.Split(str, ":")
+	params := make([]float64, len(parts)-1)
+
+	for i, s := range parts[1:] {
+		p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+
+		if err != nil {
+			return err
+		}
+
+		params[i] = p
+	}
+
+	dist := qlap.KeyDistribution(strings.TrimSpace(parts[0]))
+	opts.KeyDistribution = dist
+
+	switch dist {
+	case qlap.KeyDistributionUniform:
+		if len(params) > 0 {
+			return fmt.Errorf("%s takes no parameters", dist)
+		}
+	case qlap.KeyDistributionZipfian, qlap.KeyDistributionLatest:
+		opts.ZipfianTheta = qlap.DefaultZipfianTheta
+
+		if len(params) > 1 {
+			return fmt.Errorf("%s takes THETA only", dist)
+		} else if len(params) == 1 {
+			opts.ZipfianTheta = params[0]
+		}
+
+		if opts.ZipfianTheta <= 0 || opts.ZipfianTheta >= 1 {
+			return fmt.Errorf("theta must be > 0 and < 1: %g", opts.ZipfianTheta)
+		}
+	case qlap.KeyDistributionHotspot:
+		opts.HotspotKeys = qlap.DefaultHotspotKeys
+		opts.HotspotAccess = qlap.DefaultHotspotAccess
+
+		if len(params) != 0 && len(params) != 2 {
+			return fmt.Errorf("%s takes KEYS%% and ACCESS%%", dist)
+		} else if len(params) == 2 {
+			opts.HotspotKeys = params[0]
+			opts.HotspotAccess = params[1]
+		}
+
+		if opts.HotspotKeys <= 0 || opts.HotspotKeys >= 100 {
+			return fmt.Errorf("percentage of hot keys must be > 0 and < 100: %g", opts.HotspotKeys)
+		}
+
+		if opts.HotspotAccess < 0 || opts.HotspotAccess > 100 {
+			return fmt.Errorf("percentage of accesses to hot keys must be between 0 and 100: %g", opts.HotspotAccess)
+		}
+	default:
+		return fmt.Errorf("unknown distribution: %s", parts[0])
+	}
+
+	return nil
+}
+
+// e.g. "bigint:2:index,decimal(10,2),enum(a,b,c)" (TYPE[(ARGS)][:COUNT][:index],...)
+func parseColumns(str string) ([]qlap.ColumnSpec, error) {
+	specs := []qlap.ColumnSpec{}
+
+	for _, item := range splitOutsideParens(str) {
+		item = strings.TrimSpace(item)
+		typ, opts := item, ""
+
+		if i := strings.Index(item, ":"); i >= 0 && !strings.Contains(item[:i], "(") {
+			typ, opts = item[:i], item[i:]
+		}
+
+		var args []string
+
+		if i := strings.Index(item, "("); i >= 0 {
+			j := strings.LastIndex(item, ")")
+
+			if j < i {
+				return nil, fmt.Errorf("invalid format: %s", item)
+			}
+
+			typ, opts = item[:i], item[j+1:]
+
+			for _, a := range strings.Split(item[i+1:j], ",") {
+				args = append(args, strings.Trim(strings.TrimSpace(a), "'\""))
+			}
+		}
+
+		spec := qlap.ColumnSpec{Type: qlap.ColumnType(strings.ToLower(strings.TrimSpace(typ))), Count: 1}
+
+		for _, o := range strings.Split(strings.TrimPrefix(opts, ":"), ":") {
+			o = strings.TrimSpace(o)
+
+			if o == "" {
+				continue
+			} else if strings.EqualFold(o, "index") {
+				spec.Index = true
+			} else if n, err := strconv.Atoi(o); err == nil {
+				spec.Count = n
+			} else {
+				return nil, fmt.Errorf("invalid option: %s", item)
+			}
+		}
+
+		err := setColumnArgs(&spec, args)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", err, item)
+		}
+
+		err = spec.Validate()
+
+		if err != nil {
+			return nil, err
+		}
+
+		specs = append(specs, spec)
+	}
+
+	varcharSize := 0
+
+	for _, spec := range specs {
+		if spec.Type == qlap.ColumnTypeVarchar {
+			varcharSize += spec.Size * spec.Count
+		}
+	}
+
+	if varcharSize > qlap.MaxVarcharSize {
+		return nil, fmt.Errorf("total size of varchar columns must be <= %d", qlap.MaxVarcharSize)
+	}
+
+	return specs, nil
+}
+
+func setColumnArgs(spec *qlap.ColumnSpec, args []string) error {
+	switch spec.Type {
+	case qlap.ColumnTypeEnum:
+		spec.Values = args
+		return nil
+	case qlap.ColumnTypeDecimal:
+		spec.Size, spec.Scale = qlap.DefaultDecimalPrecision, qlap.DefaultDecimalScale
+	case qlap.ColumnTypeVarchar:
+		spec.Size = qlap.DefaultVarcharSize
+	case qlap.ColumnTypeText, qlap.ColumnTypeBlob:
+		spec.Size = qlap.DefaultTextSize
+	case qlap.ColumnTypeJSON:
+		spec.Size = qlap.DefaultJSONSize
+	default:
+		if len(args) > 0 {
+			return fmt.Errorf("size cannot be specified")
+		}
+
+		return nil
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	if len(args) > 2 || (len(args) == 2 && spec.Type != qlap.ColumnTypeDecimal) {
+		return fmt.Errorf("too many arguments")
+	}
+
+	var err error
+	spec.Size, err = strconv.Atoi(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 2 {
+		spec.Scale, err = strconv.Atoi(args[1])
+
+		if err != nil {
+			return err
+		}
+	} else if spec.Type == qlap.ColumnTypeDecimal {
+		spec.Scale = 0
+	}
+
+	return nil
+}
+
+// Split by commas except in parentheses
+func splitOutsideParens(str string) []string {
+	items := []string{}
+	depth := 0
+	start := 0
+
+	for i, c := range str {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, str[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(items, str[start:])
+}
+
 func filterEmptyQuery(queries []string) []string {
 	filtered := []string{}
 
@@ -274,3 +1280,99 @@ func filterEmptyQuery(queries []string) []string {
 
 	return filtered
 }
+
+// Whether the flag is given on the command line by its long name or short name
+func flagUsed(name string) bool {
+	f := lookupFlag(name)
+
+	if f == nil {
+		return false
+	}
+
+	if boolFlagsSet[f.LongName] {
+		return true
+	}
+
+	for _, pv := range flaggy.DefaultParser.ParsedValues {
+		if !pv.IsPositional && (pv.Key == f.LongName || f.ShortName != "" && pv.Key == f.ShortName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lookupFlag(name string) *flaggy.Flag {
+	for _, f := range flaggy.DefaultParser.Flags {
+		if f.LongName == name || f.ShortName != "" && f.ShortName == name {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// Rewrite '--flag=value' as '--flag value', since flaggy does not skip the value of '--flag=value' when it checks unknown arguments.
+// Bool flags take no separate value, so '--flag=true' and '--flag=false' are applied here
+// to turn off the bool options in the scenario file.
+func splitFlagValues(args []string) []string {
+	newArgs := []string{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			return append(newArgs, args[i:]...)
+		}
+
+		if !strings.HasPrefix(arg, "-") {
+			newArgs = append(newArgs, arg)
+			continue
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		value := ""
+		hasValue := false
+
+		if j := strings.Index(name, "="); j >= 0 {
+			name, value, hasValue = name[:j], name[j+1:], true
+		}
+
+		f := lookupFlag(name)
+
+		// NOTE: Let flaggy handle unknown flags
+		if f == nil {
+			newArgs = append(newArgs, arg)
+			continue
+		}
+
+		if b, ok := f.AssignmentVar.(*bool); ok {
+			if !hasValue {
+				newArgs = append(newArgs, arg)
+				continue
+			}
+
+			v, err := strconv.ParseBool(value)
+
+			if err != nil {
+				printErrorAndExit(fmt.Sprintf("Invalid value of '--%s': %s", f.LongName, value))
+			}
+
+			*b = v
+			boolFlagsSet[f.LongName] = true
+			continue
+		}
+
+		if hasValue {
+			newArgs = append(newArgs, arg[:len(arg)-len(value)-1], value)
+		} else if i+1 < len(args) {
+			// NOTE: The value may start with "-"
+			newArgs = append(newArgs, arg, args[i+1])
+			i++
+		} else {
+			newArgs = append(newArgs, arg)
+		}
+	}
+
+	return newArgs
+}
@@ -1,14 +1,38 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
 	"log"
+	"os"
 	"qlap"
 )
 
 func main() {
 	flags := parseFlags()
+
+	if flags.Compare != nil {
+		regressed, err := compareReports(os.Stdout, flags.Compare)
+
+		if err != nil {
+			log.Fatalf("Failed to compare reports: %s", err)
+		}
+
+		if regressed {
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if flags.Report != nil {
+		err := rebuildReport(os.Stdout, flags)
+
+		if err != nil {
+			log.Fatalf("Failed to rebuild report: %s", err)
+		}
+
+		return
+	}
+
 	task := qlap.NewTask(&flags.TaskOpts, &flags.DataOpts, &flags.RecorderOpts)
 	err := task.Prepare()
 
@@ -16,10 +40,34 @@ func main() {
 		log.Fatalf("Failed to prepare Task: %s", err)
 	}
 
-	rec, err := task.Run()
+	var rr *qlap.RecorderReport
+	var pr *qlap.PhasesReport
 
-	if err != nil {
-		log.Fatalf("Failed to run Task: %s", err)
+	if len(flags.Phases) == 0 {
+		rec, err := task.Run()
+
+		if err != nil {
+			log.Fatalf("Failed to run Task: %s", err)
+		}
+
+		rr = rec.Report()
+	} else {
+		reports := make([]*qlap.RecorderReport, 0, len(flags.Phases))
+
+		for _, phase := range flags.Phases {
+			rec, err := task.RunPhase(phase)
+
+			if err != nil {
+				log.Fatalf("Failed to run Task (phase=%s): %s", phase.Name, err)
+			}
+
+			reports = append(reports, rec.Report())
+		}
+
+		pr = &qlap.PhasesReport{
+			Phases:  reports,
+			Summary: qlap.Summarize(reports, flags.KneeThreshold),
+		}
 	}
 
 	err = task.Close()
@@ -29,8 +77,14 @@ func main() {
 	}
 
 	if !flags.OnlyPrint {
-		report := rec.Report()
-		rawJson, _ := json.MarshalIndent(report, "", "  ")
-		fmt.Println(string(rawJson))
+		if pr != nil {
+			err = printPhasesReport(os.Stdout, flags.OutputFormat, pr)
+		} else {
+			err = printReport(os.Stdout, flags.OutputFormat, rr)
+		}
+
+		if err != nil {
+			log.Fatalf("Failed to print report: %s", err)
+		}
 	}
 }
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"qlap"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/winebarrel/tachymeter"
+)
+
+const (
+	OutputFormatJSON     = "json"
+	OutputFormatText     = "text"
+	OutputFormatMarkdown = "markdown"
+	OutputFormatCSV      = "csv"
+)
+
+const outageTimeFormat = "15:04:05.000"
+
+var csvHeader = []string{
+	"phase", "nagents", "elapsed_sec", "queries", "errors", "qps",
+	"min_ms", "avg_ms", "p50_ms", "p75_ms", "p95_ms", "p99_ms", "p999_ms", "max_ms",
+}
+
+func isValidOutputFormat(format string) bool {
+	return format == OutputFormatJSON ||
+		format == OutputFormatText ||
+		format == OutputFormatMarkdown ||
+		format == OutputFormatCSV
+}
+
+func printReport(w io.Writer, format string, rr *qlap.RecorderReport) error {
+	switch format {
+	case OutputFormatText:
+		printTextReport(w, rr)
+	case OutputFormatMarkdown:
+		printMarkdownReport(w, rr)
+	case OutputFormatCSV:
+		return printCSVReports(w, []*qlap.RecorderReport{rr})
+	default:
+		return printJSON(w, rr)
+	}
+
+	return nil
+}
+
+func printPhasesReport(w io.Writer, format string, pr *qlap.PhasesReport) error {
+	switch format {
+	case OutputFormatText:
+		for _, rr := range pr.Phases {
+			fmt.Fprintf(w, "[%s]\n", rr.Phase)
+			printTextReport(w, rr)
+			fmt.Fprintln(w)
+		}
+
+		printTextSummary(w, pr.Summary)
+	case OutputFormatMarkdown:
+		for _, rr := range pr.Phases {
+			fmt.Fprintf(w, "### %s\n\n", rr.Phase)
+			printMarkdownReport(w, rr)
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprint(w, "### Summary\n\n")
+		printMarkdownSummary(w, pr.Summary)
+	case OutputFormatCSV:
+		return printCSVReports(w, pr.Phases)
+	default:
+		return printJSON(w, pr)
+	}
+
+	return nil
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	rawJson, err := json.MarshalIndent(v, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(rawJson))
+
+	return err
+}
+
+func printTextReport(w io.Writer, rr *qlap.RecorderReport) {
+	rate := "unlimited"
+
+	if rr.Rate > 0 {
+		rate = fmt.Sprintf("%d qps per agent", rr.Rate)
+	} else if len(rr.RateStages) > 0 {
+		rate = "staged"
+	}
+
+	fmt.Fprintln(w, "General:")
+	fmt.Fprintf(w, "    %-24s %s\n", "started at:", rr.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "    %-24s %ds\n", "elapsed time:", rr.ElapsedTime)
+	fmt.Fprintf(w, "    %-24s %ds\n", "warm-up time:", rr.WarmUpTime)
+	fmt.Fprintf(w, "    %-24s %d\n", "agents:", rr.NAgents)
+	fmt.Fprintf(w, "    %-24s %s\n", "rate:", rate)
+
+	if kd := keyDistribution(&rr.DataOpts); kd != "" {
+		fmt.Fprintf(w, "    %-24s %s\n", "key distribution:", kd)
+	}
+
+	fmt.Fprintln(w, "Queries:")
+	fmt.Fprintf(w, "    %-24s %d (%.2f per sec.)\n", "total:", rr.QueryCount, rr.AvgQPS)
+	fmt.Fprintf(w, "    %-24s %d\n", "errors:", rr.ErrorCount)
+	fmt.Fprintf(w, "    %-24s %d\n", "rows returned:", rr.RowsReturned)
+	fmt.Fprintf(w, "    %-24s %d\n", "bytes read:", rr.BytesRead)
+	fmt.Fprintf(w, "    %-24s %.0f / %.0f / %.0f\n", "qps min/median/max:", rr.MinQPS, rr.MedianQPS, rr.MaxQPS)
+	printTextLatency(w, "Latency (ms):", rr.Response)
+
+	for _, name := range sortedPercentileNames(rr) {
+		fmt.Fprintf(w, "    %-24s %s\n", strings.ToLower(name)+":", percentileMs(rr.Percentiles[name]))
+	}
+
+	if rr.CorrectedResponse != nil {
+		printTextLatency(w, "Corrected latency (ms):", rr.CorrectedResponse)
+	}
+
+	if len(rr.Statements) > 0 {
+		fmt.Fprintln(w, "Statements:")
+		fmt.Fprintf(w, "    %-16s %10s %10s %8s %10s %10s %10s\n", "NAME", "QUERIES", "QPS", "ERRORS", "AVG(ms)", "P95(ms)", "P99(ms)")
+
+		for _, tag := range sortedStatementTags(rr) {
+			sr := rr.Statements[tag]
+			fmt.Fprintf(w, "    %-16s %10d %10.2f %8d %10s %10s %10s\n", tag, sr.QueryCount, sr.AvgQPS, sr.ErrorCount,
+				ms(sr.Response.Time.Avg), ms(sr.Response.Time.P95), ms(sr.Response.Time.P99))
+		}
+	}
+
+	if len(rr.Agents) > 0 {
+		fmt.Fprintln(w, "Agents:")
+		fmt.Fprintf(w, "    %-6s %10s %10s %8s %10s %8s %10s %10s %10s %10s\n", "ID", "QUERIES", "QPS", "ERRORS", "RECONNECTS", "FORCED", "P50(ms)", "P95(ms)", "P99(ms)", "DEVIATION")
+
+		for _, ar := range rr.Agents {
+			fmt.Fprintf(w, "    %-6d %10d %10.2f %8d %10d %8d %10s %10s %10s %9.2f%%", ar.Id, ar.QueryCount, ar.AvgQPS, ar.ErrorCount,
+				ar.Reconnects, ar.ForcedReconnects, ms(ar.P50), ms(ar.P95), ms(ar.P99), ar.Deviation)
+
+			if ar.Outlier {
+				fmt.Fprint(w, "  <- outlier")
+			}
+
+			fmt.Fprintln(w)
+		}
+	}
+
+	if tr := rr.Transaction; tr != nil {
+		fmt.Fprintln(w, "Transactions:")
+		fmt.Fprintf(w, "    %-24s %d (%.2f per sec.)\n", "total:", tr.TransactionCount, tr.AvgTPS)
+		fmt.Fprintf(w, "    %-24s %d\n", "errors:", tr.ErrorCount)
+		fmt.Fprintf(w, "    %-24s %d\n", "rollbacks:", tr.RollbackCount)
+		fmt.Fprintf(w, "    %-24s %d\n", "retries:", tr.RetryCount)
+		printTextLatency(w, "Transaction latency (ms):", tr.Response)
+	}
+
+	if cr := rr.Connection; cr != nil {
+		fmt.Fprintln(w, "Connections:")
+		fmt.Fprintf(w, "    %-24s %d (%.2f per sec.)\n", "total:", cr.ConnectCount, cr.AvgCPS)
+		fmt.Fprintf(w, "    %-24s %d\n", "errors:", cr.ErrorCount)
+		printTextLatency(w, "Connect latency (ms):", cr.Response)
+	}
+
+	if len(rr.Outages) > 0 {
+		fmt.Fprintln(w, "Outages:")
+		fmt.Fprintf(w, "    %-12s %-12s %14s %14s %8s %8s\n", "FIRST ERROR", "RECOVERED", "DOWNTIME(ms)", "RECOVERY(ms)", "AGENTS", "FAILED")
+
+		for _, or := range rr.Outages {
+			recoveredAt := "-"
+
+			if or.Recovered {
+				recoveredAt = or.RecoveredAt.Format(outageTimeFormat)
+			}
+
+			fmt.Fprintf(w, "    %-12s %-12s %14s %14s %8d %8d\n", or.FirstErrorAt.Format(outageTimeFormat), recoveredAt,
+				ms(or.Downtime), ms(or.TimeToRecover), or.Agents, or.FailedQueries)
+		}
+	}
+
+	if len(rr.Errors) > 0 {
+		fmt.Fprintln(w, "Errors:")
+
+		for _, er := range rr.Errors {
+			fmt.Fprintf(w, "    %-6d %8d  %s\n", er.Number, er.Count, er.Message)
+		}
+	}
+}
+
+func printTextLatency(w io.Writer, title string, m *tachymeter.Metrics) {
+	fmt.Fprintln(w, title)
+	fmt.Fprintf(w, "    %-24s %s\n", "min:", ms(m.Time.Min))
+	fmt.Fprintf(w, "    %-24s %s\n", "avg:", ms(m.Time.Avg))
+	fmt.Fprintf(w, "    %-24s %s\n", "p50:", ms(m.Time.P50))
+	fmt.Fprintf(w, "    %-24s %s\n", "p95:", ms(m.Time.P95))
+	fmt.Fprintf(w, "    %-24s %s\n", "p99:", ms(m.Time.P99))
+	fmt.Fprintf(w, "    %-24s %s\n", "p999:", ms(m.Time.P999))
+	fmt.Fprintf(w, "    %-24s %s\n", "max:", ms(m.Time.Max))
+}
+
+func printTextSummary(w io.Writer, summary *qlap.SummaryReport) {
+	fmt.Fprintln(w, "Summary:")
+	fmt.Fprintf(w, "    %-24s %ds\n", "elapsed time:", summary.ElapsedTime)
+	fmt.Fprintf(w, "    %-24s %d (%.2f per sec.)\n", "queries:", summary.QueryCount, summary.AvgQPS)
+	fmt.Fprintf(w, "    %-24s %d\n", "errors:", summary.ErrorCount)
+	fmt.Fprintf(w, "    %-16s %8s %10s %8s %10s %10s %10s %10s\n", "PHASE", "AGENTS", "QUERIES", "ERRORS", "QPS", "P50(ms)", "P95(ms)", "P99(ms)")
+
+	for _, ps := range summary.Phases {
+		fmt.Fprintf(w, "    %-16s %8d %10d %8d %10.2f %10s %10s %10s", ps.Name, ps.NAgents, ps.QueryCount, ps.ErrorCount,
+			ps.AvgQPS, ms(ps.P50), ms(ps.P95), ms(ps.P99))
+
+		if ps.Knee {
+			fmt.Fprint(w, "  <- knee")
+		}
+
+		fmt.Fprintln(w)
+	}
+}
+
+func printMarkdownReport(w io.Writer, rr *qlap.RecorderReport) {
+	fmt.Fprintln(w, "| Metric | Value |")
+	fmt.Fprintln(w, "|---|---|")
+	fmt.Fprintf(w, "| Elapsed time (s) | %d |\n", rr.ElapsedTime)
+	fmt.Fprintf(w, "| Agents | %d |\n", rr.NAgents)
+
+	if kd := keyDistribution(&rr.DataOpts); kd != "" {
+		fmt.Fprintf(w, "| Key distribution | %s |\n", kd)
+	}
+
+	fmt.Fprintf(w, "| Queries | %d |\n", rr.QueryCount)
+	fmt.Fprintf(w, "| Errors | %d |\n", rr.ErrorCount)
+	fmt.Fprintf(w, "| QPS | %.2f |\n", rr.AvgQPS)
+	fmt.Fprintf(w, "| Avg (ms) | %s |\n", ms(rr.Response.Time.Avg))
+	fmt.Fprintf(w, "| P50 (ms) | %s |\n", ms(rr.Response.Time.P50))
+	fmt.Fprintf(w, "| P95 (ms) | %s |\n", ms(rr.Response.Time.P95))
+	fmt.Fprintf(w, "| P99 (ms) | %s |\n", ms(rr.Response.Time.P99))
+	fmt.Fprintf(w, "| P999 (ms) | %s |\n", ms(rr.Response.Time.P999))
+	fmt.Fprintf(w, "| Max (ms) | %s |\n", ms(rr.Response.Time.Max))
+
+	for _, name := range sortedPercentileNames(rr) {
+		fmt.Fprintf(w, "| %s (ms) | %s |\n", name, percentileMs(rr.Percentiles[name]))
+	}
+
+	if tr := rr.Transaction; tr != nil {
+		fmt.Fprintf(w, "| Transactions | %d |\n", tr.TransactionCount)
+		fmt.Fprintf(w, "| TPS | %.2f |\n", tr.AvgTPS)
+		fmt.Fprintf(w, "| Transaction P99 (ms) | %s |\n", ms(tr.Response.Time.P99))
+	}
+
+	if cr := rr.Connection; cr != nil {
+		fmt.Fprintf(w, "| Connections | %d |\n", cr.ConnectCount)
+		fmt.Fprintf(w, "| Connection errors | %d |\n", cr.ErrorCount)
+		fmt.Fprintf(w, "| Connect P99 (ms) | %s |\n", ms(cr.Response.Time.P99))
+	}
+
+	if len(rr.Statements) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| Statement | Queries | QPS | Errors | Avg (ms) | P95 (ms) | P99 (ms) |")
+		fmt.Fprintln(w, "|---|--:|--:|--:|--:|--:|--:|")
+
+		for _, tag := range sortedStatementTags(rr) {
+			sr := rr.Statements[tag]
+			fmt.Fprintf(w, "| %s | %d | %.2f | %d | %s | %s | %s |\n", tag, sr.QueryCount, sr.AvgQPS, sr.ErrorCount,
+				ms(sr.Response.Time.Avg), ms(sr.Response.Time.P95), ms(sr.Response.Time.P99))
+		}
+	}
+
+	if len(rr.Outages) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| First error | Recovered | Downtime (ms) | Recovery (ms) | Agents | Failed |")
+		fmt.Fprintln(w, "|---|---|--:|--:|--:|--:|")
+
+		for _, or := range rr.Outages {
+			recoveredAt := "-"
+
+			if or.Recovered {
+				recoveredAt = or.RecoveredAt.Format(outageTimeFormat)
+			}
+
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %d | %d |\n", or.FirstErrorAt.Format(outageTimeFormat), recoveredAt,
+				ms(or.Downtime), ms(or.TimeToRecover), or.Agents, or.FailedQueries)
+		}
+	}
+
+	if len(rr.Agents) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "| Agent | Queries | QPS | Errors | Reconnects | Forced reconnects | P50 (ms) | P95 (ms) | P99 (ms) | Deviation | Outlier |")
+		fmt.Fprintln(w, "|--:|--:|--:|--:|--:|--:|--:|--:|--:|--:|:-:|")
+
+		for _, ar := range rr.Agents {
+			outlier := ""
+
+			if ar.Outlier {
+				outlier = "*"
+			}
+
+			fmt.Fprintf(w, "| %d | %d | %.2f | %d | %d | %d | %s | %s | %s | %+.2f%% | %s |\n", ar.Id, ar.QueryCount, ar.AvgQPS, ar.ErrorCount,
+				ar.Reconnects, ar.ForcedReconnects, ms(ar.P50), ms(ar.P95), ms(ar.P99), ar.Deviation, outlier)
+		}
+	}
+}
+
+func printMarkdownSummary(w io.Writer, summary *qlap.SummaryReport) {
+	fmt.Fprintln(w, "| Phase | Agents | Queries | Errors | QPS | P50 (ms) | P95 (ms) | P99 (ms) | Knee |")
+	fmt.Fprintln(w, "|---|--:|--:|--:|--:|--:|--:|--:|:-:|")
+
+	for _, ps := range summary.Phases {
+		knee := ""
+
+		if ps.Knee {
+			knee = "*"
+		}
+
+		fmt.Fprintf(w, "| %s | %d | %d | %d | %.2f | %s | %s | %s | %s |\n", ps.Name, ps.NAgents, ps.QueryCount, ps.ErrorCount,
+			ps.AvgQPS, ms(ps.P50), ms(ps.P95), ms(ps.P99), knee)
+	}
+}
+
+func printCSVReports(w io.Writer, reports []*qlap.RecorderReport) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(csvHeader)
+
+	if err != nil {
+		return err
+	}
+
+	for _, rr := range reports {
+		t := rr.Response.Time
+
+		err = cw.Write([]string{
+			rr.Phase,
+			strconv.Itoa(rr.NAgents),
+			strconv.FormatInt(int64(rr.ElapsedTime), 10),
+			strconv.Itoa(rr.QueryCount),
+			strconv.Itoa(rr.ErrorCount),
+			strconv.FormatFloat(rr.AvgQPS, 'f', 2, 64),
+			ms(t.Min), ms(t.Avg), ms(t.P50), ms(t.P75), ms(t.P95), ms(t.P99), ms(t.P999), ms(t.Max),
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func sortedStatementTags(rr *qlap.RecorderReport) []string {
+	tags := make([]string, 0, len(rr.Statements))
+
+	for tag := range rr.Statements {
+		tags = append(tags, tag)
+	}
+
+	sort.Strings(tags)
+
+	return tags
+}
+
+func sortedPercentileNames(rr *qlap.RecorderReport) []string {
+	names := make([]string, 0, len(rr.Percentiles))
+
+	for name := range rr.Percentiles {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		pi, _ := strconv.ParseFloat(strings.TrimPrefix(names[i], "P"), 64)
+		pj, _ := strconv.ParseFloat(strings.TrimPrefix(names[j], "P"), 64)
+		return pi < pj
+	})
+
+	return names
+}
+
+// Key distribution with its parameters, or an empty string if it is uniform
+func keyDistribution(opts *qlap.DataOpts) string {
+	switch opts.KeyDistribution {
+	case qlap.KeyDistributionZipfian, qlap.KeyDistributionLatest:
+		return fmt.Sprintf("%s (theta=%g)", opts.KeyDistribution, opts.ZipfianTheta)
+	case qlap.KeyDistributionHotspot:
+		return fmt.Sprintf("%s (%g%% of keys get %g%% of accesses)", opts.KeyDistribution, opts.HotspotKeys, opts.HotspotAccess)
+	default:
+		return ""
+	}
+}
+
+func percentileMs(str string) string {
+	d, err := time.ParseDuration(str)
+
+	if err != nil {
+		return str
+	}
+
+	return ms(d)
+}
+
+func ms(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
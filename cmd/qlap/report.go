@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"qlap"
+)
+
+type ReportFlags struct {
+	Samples string
+}
+
+// Rebuild the report from a samples file with the analysis settings of the flags
+func rebuildReport(w io.Writer, flags *Flags) error {
+	recs, err := qlap.ReadSamples(flags.Report.Samples, &flags.RecorderOpts)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read samples: %w", err)
+	}
+
+	if len(recs) == 0 {
+		return fmt.Errorf("No samples: %s", flags.Report.Samples)
+	}
+
+	reports := make([]*qlap.RecorderReport, 0, len(recs))
+
+	for _, rec := range recs {
+		reports = append(reports, rec.Report())
+	}
+
+	if len(reports) == 1 && reports[0].Phase == "" {
+		return printReport(w, flags.OutputFormat, reports[0])
+	}
+
+	pr := &qlap.PhasesReport{
+		Phases:  reports,
+		Summary: qlap.Summarize(reports, flags.KneeThreshold),
+	}
+
+	return printPhasesReport(w, flags.OutputFormat, pr)
+}
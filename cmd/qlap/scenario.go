@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"qlap"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Scenario holds the raw values of all flags.
+// It is loaded from a YAML (or JSON) file, and flags override its values.
+type Scenario struct {
+	DSN                    string              `yaml:"dsn"`
+	NAgents                string              `yaml:"nagents"`
+	Time                   int                 `yaml:"time"`
+	NumberQueries          int                 `yaml:"number-queries"`
+	Rate                   int                 `yaml:"rate"`
+	RateRamp               string              `yaml:"rate-ramp"`
+	RateSteps              string              `yaml:"rate-steps"`
+	RateStages             []ScenarioRateStage `yaml:"rate-stages"`
+	WarmUp                 int                 `yaml:"warm-up"`
+	OpenLoop               bool                `yaml:"open-loop"`
+	OpenLoopWorkers        int                 `yaml:"open-loop-workers"`
+	OpenLoopMaxWorkers     int                 `yaml:"open-loop-max-workers"`
+	AutoGenerateSql        bool                `yaml:"auto-generate-sql"`
+	GuidPrimary            bool                `yaml:"auto-generate-sql-guid-primary"`
+	Query                  string              `yaml:"query"`
+	Queries                []ScenarioQuery     `yaml:"queries"`
+	QueryWeights           string              `yaml:"query-weights"`
+	QueryNames             string              `yaml:"query-names"`
+	NumberPrePopulatedData int                 `yaml:"auto-generate-sql-write-number"`
+	LoadType               string              `yaml:"auto-generate-sql-load-type"`
+	NumberSecondaryIndexes int                 `yaml:"auto-generate-sql-secondary-indexes"`
+	PreparedStatement      bool                `yaml:"prepared-statement"`
+	CommitRate             int                 `yaml:"commit-rate"`
+	Transaction            bool                `yaml:"transaction"`
+	TransactionRetries     int                 `yaml:"transaction-retries"`
+	MixedSelInsRatio       string              `yaml:"mixed-sel-ins-ratio"`
+	MixRatio               string              `yaml:"mix-ratio"`
+	RangeSize              int                 `yaml:"range-size"`
+	Engine                 string              `yaml:"engine"`
+	NumberCharCols         int                 `yaml:"number-char-cols"`
+	CharColsIndex          bool                `yaml:"char-cols-index"`
+	NumberIntCols          int                 `yaml:"number-int-cols"`
+	IntColsIndex           bool                `yaml:"int-cols-index"`
+	Columns                string              `yaml:"columns"`
+	KeyDistribution        string              `yaml:"key-distribution"`
+	Table                  string              `yaml:"table"`
+	NumberTableKeys        int                 `yaml:"table-keys"`
+	PreQuery               string              `yaml:"pre-query"`
+	Create                 string              `yaml:"create"`
+	DropDB                 bool                `yaml:"drop-db"`
+	NoDrop                 bool                `yaml:"no-drop"`
+	HInterval              string              `yaml:"hinterval"`
+	HistogramPrecision     int                 `yaml:"histogram-precision"`
+	Percentiles            string              `yaml:"percentiles"`
+	AgentStats             bool                `yaml:"agent-stats"`
+	AgentDeviation         float64             `yaml:"agent-deviation"`
+	TimeSeries             string              `yaml:"time-series"`
+	TimeSeriesFormat       string              `yaml:"time-series-format"`
+	MetricsAddr            string              `yaml:"metrics-addr"`
+	Samples                string              `yaml:"samples"`
+	Delimiter              string              `yaml:"delimiter"`
+	OnError                string              `yaml:"on-error"`
+	MaxErrors              int                 `yaml:"max-errors"`
+	ConnectionMode         string              `yaml:"connection-mode"`
+	ReconnectEvery         int                 `yaml:"reconnect-every"`
+	AutoReconnect          bool                `yaml:"auto-reconnect"`
+	MaxReconnectBackoff    string              `yaml:"max-reconnect-backoff"`
+	OnlyPrint              bool                `yaml:"only-print"`
+	NoProgress             bool                `yaml:"no-progress"`
+	KneeThreshold          float64             `yaml:"knee-threshold"`
+	OutputFormat           string              `yaml:"output-format"`
+	Phases                 []ScenarioPhase     `yaml:"phases"`
+	keys                   map[string]bool     // Keys set in the file
+}
+
+// A custom query with its name and weight
+type ScenarioQuery struct {
+	Name   string `yaml:"name"`
+	Weight *int   `yaml:"weight"`
+	SQL    string `yaml:"sql"`
+}
+
+// A rate stage: '{rate: RATE, duration: DURATION}' or '{from: FROM, to: TO, duration: DURATION}'
+type ScenarioRateStage struct {
+	Rate     *int   `yaml:"rate"`
+	From     *int   `yaml:"from"`
+	To       *int   `yaml:"to"`
+	Duration string `yaml:"duration"`
+}
+
+// A phase of the run. Omitted values are inherited from the top level.
+type ScenarioPhase struct {
+	Name          string              `yaml:"name"`
+	NAgents       *int                `yaml:"nagents"`
+	Time          *int                `yaml:"time"`
+	NumberQueries *int                `yaml:"number-queries"`
+	Rate          *int                `yaml:"rate"`
+	RateStages    []ScenarioRateStage `yaml:"rate-stages"`
+	WarmUp        *int                `yaml:"warm-up"`
+	LoadType      string              `yaml:"auto-generate-sql-load-type"`
+}
+
+func newScenario() *Scenario {
+	return &Scenario{
+		keys:                   map[string]bool{},
+		NAgents:                "1",
+		Time:                   DefaultTime,
+		OpenLoopWorkers:        DefaultOpenLoopWorkers,
+		OpenLoopMaxWorkers:     DefaultOpenLoopMaxWorkers,
+		NumberPrePopulatedData: DefaultNumberPrePopulatedData,
+		LoadType:               DefaultLoadType,
+		TransactionRetries:     DefaultTransactionRetries,
+		MixedSelInsRatio:       DefaultMixedSelInsRatio,
+		RangeSize:              DefaultRangeSize,
+		NumberCharCols:         DefaultNumberCharCols,
+		NumberIntCols:          DefaultNumberIntCols,
+		KeyDistribution:        DefaultKeyDistribution,
+		NumberTableKeys:        DefaultNumberTableKeys,
+		HInterval:              "0",
+		HistogramPrecision:     qlap.DefaultHistogramPrecision,
+		AgentDeviation:         DefaultAgentDeviation,
+		ConnectionMode:         DefaultConnectionMode,
+		ReconnectEvery:         1,
+		MaxReconnectBackoff:    DefaultMaxReconnectBackoff,
+		TimeSeriesFormat:       DefaultTimeSeriesFormat,
+		Delimiter:              DefaultDelimiter,
+		OnError:                DefaultOnError,
+		OutputFormat:           DefaultOutputFormat,
+	}
+}
+
+// Find the scenario file in the arguments before parsing flags,
+// so that the flags can override the values in the file
+func scenarioPath(args []string) string {
+	for i, arg := range args {
+		if arg == "--scenario" && i+1 < len(args) {
+			return args[i+1]
+		} else if strings.HasPrefix(arg, "--scenario=") {
+			return strings.TrimPrefix(arg, "--scenario=")
+		}
+	}
+
+	return ""
+}
+
+func (sc *Scenario) load(path string) error {
+	rawScenario, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	err = yaml.UnmarshalStrict(rawScenario, sc)
+
+	if err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(rawScenario, &values)
+
+	if err != nil {
+		return err
+	}
+
+	for k := range values {
+		sc.keys[k] = true
+	}
+
+	if sc.Query != "" && len(sc.Queries) > 0 {
+		return fmt.Errorf("cannot set both 'query' and 'queries'")
+	}
+
+	if len(sc.RateStages) > 0 && (sc.Rate != 0 || sc.RateRamp != "" || sc.RateSteps != "") {
+		return fmt.Errorf("cannot set both 'rate-stages' and 'rate'/'rate-ramp'/'rate-steps'")
+	}
+
+	return nil
+}
+
+// Whether the option is set by the flag or in the file
+func (sc *Scenario) has(name string) bool {
+	return sc.keys[name] || flagUsed(name)
+}
+
+func (sc *Scenario) hasQueryWeights() bool {
+	for _, sq := range sc.Queries {
+		if sq.Weight != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (sc *Scenario) hasQueryNames() bool {
+	for _, sq := range sc.Queries {
+		if sq.Name != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (sq *ScenarioQuery) weight() int {
+	if sq.Weight == nil {
+		return 1
+	}
+
+	return *sq.Weight
+}
+
+func (stage *ScenarioRateStage) rateStage() (*qlap.RateStage, error) {
+	d, err := time.ParseDuration(stage.Duration)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d <= 0 {
+		return nil, fmt.Errorf("duration must be > 0: %s", stage.Duration)
+	}
+
+	if stage.Rate != nil {
+		if stage.From != nil || stage.To != nil {
+			return nil, fmt.Errorf("cannot set both 'rate' and 'from'/'to'")
+		}
+
+		if *stage.Rate < 1 {
+			return nil, fmt.Errorf("rate must be >= 1: %d", *stage.Rate)
+		}
+
+		return &qlap.RateStage{FromRate: *stage.Rate, ToRate: *stage.Rate, Duration: d}, nil
+	}
+
+	if stage.From == nil || stage.To == nil {
+		return nil, fmt.Errorf("either 'rate' or 'from' and 'to' is required")
+	}
+
+	if *stage.From < 1 || *stage.To < 1 {
+		return nil, fmt.Errorf("rate must be >= 1: %d:%d", *stage.From, *stage.To)
+	}
+
+	return &qlap.RateStage{FromRate: *stage.From, ToRate: *stage.To, Duration: d}, nil
+}
+
+func (sp *ScenarioPhase) phase(flags *Flags) (*qlap.Phase, error) {
+	phase := &qlap.Phase{
+		Name:                   sp.Name,
+		NAgents:                flags.NAgents,
+		Time:                   flags.Time,
+		NumberQueriesToExecute: flags.NumberQueriesToExecute,
+		Rate:                   flags.Rate,
+		RateStages:             flags.RateStages,
+		WarmUp:                 flags.WarmUp,
+		LoadType:               flags.LoadType,
+	}
+
+	if phase.Name == "" {
+		return nil, fmt.Errorf("'name' is required")
+	}
+
+	if sp.NAgents != nil {
+		if *sp.NAgents < 1 {
+			return nil, fmt.Errorf("'nagents' must be >= 1")
+		}
+
+		phase.NAgents = *sp.NAgents
+	}
+
+	if sp.Time != nil {
+		if *sp.Time < 0 {
+			return nil, fmt.Errorf("'time' must be >= 0")
+		}
+
+		phase.Time = time.Duration(*sp.Time) * time.Second
+	}
+
+	if sp.NumberQueries != nil {
+		if *sp.NumberQueries < 0 {
+			return nil, fmt.Errorf("'number-queries' must be >= 0")
+		}
+
+		phase.NumberQueriesToExecute = *sp.NumberQueries
+	}
+
+	if sp.Rate != nil && len(sp.RateStages) > 0 {
+		return nil, fmt.Errorf("cannot set both 'rate' and 'rate-stages'")
+	}
+
+	if sp.Rate != nil {
+		if *sp.Rate < 0 {
+			return nil, fmt.Errorf("'rate' must be >= 0")
+		}
+
+		phase.Rate = *sp.Rate
+		phase.RateStages = nil
+	}
+
+	if len(sp.RateStages) > 0 {
+		phase.Rate = 0
+		phase.RateStages = nil
+
+		for i, sst := range sp.RateStages {
+			stage, err := sst.rateStage()
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid rate stage #%d: %w", i, err)
+			}
+
+			phase.RateStages = append(phase.RateStages, *stage)
+		}
+	}
+
+	if sp.WarmUp != nil {
+		if *sp.WarmUp < 0 {
+			return nil, fmt.Errorf("'warm-up' must be >= 0")
+		}
+
+		phase.WarmUp = time.Duration(*sp.WarmUp) * time.Second
+	}
+
+	if phase.Time > 0 && phase.WarmUp >= phase.Time {
+		return nil, fmt.Errorf("'warm-up' must be < 'time'")
+	}
+
+	if flags.OpenLoop && phase.Rate == 0 && len(phase.RateStages) == 0 {
+		return nil, fmt.Errorf("'open-loop' requires 'rate' or 'rate-stages'")
+	}
+
+	if sp.LoadType != "" {
+		if !flags.AutoGenerateSql {
+			return nil, fmt.Errorf("'auto-generate-sql' is required for 'auto-generate-sql-load-type'")
+		}
+
+		loadType := qlap.AutoGenerateSqlLoadType(sp.LoadType)
+
+		if !isValidLoadType(loadType) {
+			return nil, fmt.Errorf("invalid load type: %s", sp.LoadType)
+		}
+
+		if flags.Table == "" && flags.NumberPrePopulatedData == 0 && requiresPrePopulatedData(loadType) {
+			return nil, fmt.Errorf("pre-populated data is required for '%s'", sp.LoadType)
+		}
+
+		phase.LoadType = loadType
+	}
+
+	return phase, nil
+}
@@ -0,0 +1,248 @@
+package qlap
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/winebarrel/randstr"
+)
+
+type ColumnType string
+
+const (
+	ColumnTypeInt      = ColumnType("int")
+	ColumnTypeBigint   = ColumnType("bigint")
+	ColumnTypeDecimal  = ColumnType("decimal")
+	ColumnTypeVarchar  = ColumnType("varchar")
+	ColumnTypeText     = ColumnType("text")
+	ColumnTypeBlob     = ColumnType("blob")
+	ColumnTypeDatetime = ColumnType("datetime")
+	ColumnTypeJSON     = ColumnType("json")
+	ColumnTypeEnum     = ColumnType("enum")
+)
+
+const (
+	DefaultVarcharSize      = 128
+	DefaultTextSize         = 1024
+	DefaultJSONSize         = 64
+	DefaultDecimalPrecision = 10
+	DefaultDecimalScale     = 2
+	MaxDecimalPrecision     = 18
+	MaxVarcharSize          = 16000    // A row is up to 65535 bytes, and utf8mb4 takes up to 4 bytes per character
+	MaxIndexedVarcharSize   = 768      // An index key is up to 3072 bytes
+	MaxTextSize             = 16777215 // MEDIUMTEXT and MEDIUMBLOB
+	maxShortTextSize        = 65535    // TEXT and BLOB
+	textIndexPrefix         = 64
+)
+
+// Columns of the same type in the auto-generated table
+type ColumnSpec struct {
+	Type   ColumnType
+	Size   int      // Length of VARCHAR, TEXT, BLOB and JSON values, or precision of DECIMAL
+	Scale  int      // Scale of DECIMAL
+	Values []string // Values of ENUM
+	Count  int
+	Index  bool
+}
+
+func (spec *ColumnSpec) Validate() error {
+	switch spec.Type {
+	case ColumnTypeInt, ColumnTypeBigint, ColumnTypeDatetime:
+		// Nothing to do
+	case ColumnTypeVarchar:
+		if spec.Size < 1 || spec.Size > MaxVarcharSize {
+			return fmt.Errorf("size of %s must be between 1 and %d", spec.Type, MaxVarcharSize)
+		}
+
+		if spec.Index && spec.Size > MaxIndexedVarcharSize {
+			return fmt.Errorf("size of indexed %s must be <= %d", spec.Type, MaxIndexedVarcharSize)
+		}
+	case ColumnTypeText, ColumnTypeBlob:
+		if spec.Size < 1 || spec.Size > MaxTextSize {
+			return fmt.Errorf("size of %s must be between 1 and %d", spec.Type, MaxTextSize)
+		}
+	case ColumnTypeJSON:
+		if spec.Size < 1 {
+			return fmt.Errorf("size of %s must be >= 1", spec.Type)
+		}
+
+		if spec.Index {
+			return fmt.Errorf("%s column cannot be indexed", spec.Type)
+		}
+	case ColumnTypeDecimal:
+		if spec.Size < 1 || spec.Size > MaxDecimalPrecision {
+			return fmt.Errorf("precision of %s must be between 1 and %d", spec.Type, MaxDecimalPrecision)
+		}
+
+		if spec.Scale < 0 || spec.Scale > spec.Size {
+			return fmt.Errorf("scale of %s must be between 0 and the precision", spec.Type)
+		}
+	case ColumnTypeEnum:
+		if len(spec.Values) == 0 {
+			return fmt.Errorf("values of %s are required", spec.Type)
+		}
+	default:
+		return fmt.Errorf("unknown column type: %s", spec.Type)
+	}
+
+	if spec.Count < 1 {
+		return fmt.Errorf("count of %s must be >= 1", spec.Type)
+	}
+
+	return nil
+}
+
+// Column specs from '--number-int-cols' and '--number-char-cols' if no specs are given
+func (opts *DataOpts) columnSpecs() []ColumnSpec {
+	if len(opts.Columns) > 0 {
+		return opts.Columns
+	}
+
+	specs := []ColumnSpec{}
+
+	if opts.NumberIntCols > 0 {
+		specs = append(specs, ColumnSpec{Type: ColumnTypeInt, Count: opts.NumberIntCols, Index: opts.IntColsIndex})
+	}
+
+	if opts.NumberCharCols > 0 {
+		specs = append(specs, ColumnSpec{Type: ColumnTypeVarchar, Size: DefaultVarcharSize, Count: opts.NumberCharCols, Index: opts.CharColsIndex})
+	}
+
+	return specs
+}
+
+type column struct {
+	name  string
+	spec  *ColumnSpec
+	value func(src rand.Source) interface{}
+}
+
+// Name columns by type, e.g. "intcol1", "charcol1", "bigintcol1"
+func newColumns(specs []ColumnSpec) []*column {
+	cols := []*column{}
+	numByType := map[ColumnType]int{}
+
+	for i := range specs {
+		spec := &specs[i]
+		prefix := string(spec.Type)
+
+		if spec.Type == ColumnTypeVarchar {
+			prefix = "char"
+		}
+
+		for j := 0; j < spec.Count; j++ {
+			numByType[spec.Type]++
+
+			cols = append(cols, &column{
+				name:  fmt.Sprintf("%scol%d", prefix, numByType[spec.Type]),
+				spec:  spec,
+				value: spec.generator(),
+			})
+		}
+	}
+
+	return cols
+}
+
+func (col *column) definition() string {
+	spec := col.spec
+	var typ string
+
+	switch spec.Type {
+	case ColumnTypeInt:
+		typ = "INT(32)"
+	case ColumnTypeDecimal:
+		typ = fmt.Sprintf("DECIMAL(%d,%d)", spec.Size, spec.Scale)
+	case ColumnTypeVarchar:
+		typ = fmt.Sprintf("VARCHAR(%d)", spec.Size)
+	case ColumnTypeText, ColumnTypeBlob:
+		typ = strings.ToUpper(string(spec.Type))
+
+		if spec.Size > maxShortTextSize {
+			typ = "MEDIUM" + typ
+		}
+	case ColumnTypeEnum:
+		values := make([]string, len(spec.Values))
+
+		for i, v := range spec.Values {
+			values[i] = sqlLiteral(v)
+		}
+
+		typ = "ENUM(" + strings.Join(values, ",") + ")"
+	default:
+		typ = strings.ToUpper(string(spec.Type))
+	}
+
+	return col.name + " " + typ
+}
+
+// Index definition, or an empty string if the column is not indexed
+func (col *column) index() string {
+	if !col.spec.Index {
+		return ""
+	}
+
+	// NOTE: TEXT and BLOB columns require the prefix length
+	if col.spec.Type == ColumnTypeText || col.spec.Type == ColumnTypeBlob {
+		return fmt.Sprintf("INDEX(%s(%d))", col.name, textIndexPrefix)
+	}
+
+	return "INDEX(" + col.name + ")"
+}
+
+func (spec *ColumnSpec) generator() func(src rand.Source) interface{} {
+	switch spec.Type {
+	case ColumnTypeInt:
+		return func(src rand.Source) interface{} {
+			return src.Int63() >> 32
+		}
+	case ColumnTypeBigint:
+		return func(src rand.Source) interface{} {
+			return src.Int63()
+		}
+	case ColumnTypeDecimal:
+		max := int64(math.Pow10(spec.Size))
+		scale := int64(math.Pow10(spec.Scale))
+
+		return func(src rand.Source) interface{} {
+			v := src.Int63() % max
+
+			if spec.Scale == 0 {
+				return strconv.FormatInt(v, 10)
+			}
+
+			return fmt.Sprintf("%d.%0*d", v/scale, spec.Scale, v%scale)
+		}
+	case ColumnTypeDatetime:
+		// Within 10 years before now
+		now := time.Now().Unix()
+		span := int64(10 * 365 * 24 * time.Hour / time.Second)
+
+		return func(src rand.Source) interface{} {
+			return time.Unix(now-src.Int63()%span, 0).UTC().Format("2006-01-02 15:04:05")
+		}
+	case ColumnTypeJSON:
+		// e.g. {"n":123,"s":"abc..."}
+		strLen := spec.Size - len(`{"n":,"s":""}`) - 10
+
+		if strLen < 0 {
+			strLen = 0
+		}
+
+		return func(src rand.Source) interface{} {
+			return fmt.Sprintf(`{"n":%d,"s":"%s"}`, src.Int63()>>32, randstr.String(src, strLen))
+		}
+	case ColumnTypeEnum:
+		return func(src rand.Source) interface{} {
+			return spec.Values[src.Int63()%int64(len(spec.Values))]
+		}
+	default:
+		return func(src rand.Source) interface{} {
+			return randstr.String(src, spec.Size)
+		}
+	}
+}
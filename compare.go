@@ -0,0 +1,121 @@
+package qlap
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	CompareMetricQPS  = "qps"
+	CompareMetricAvg  = "avg"
+	CompareMetricP50  = "p50"
+	CompareMetricP75  = "p75"
+	CompareMetricP95  = "p95"
+	CompareMetricP99  = "p99"
+	CompareMetricP999 = "p999"
+	CompareMetricMax  = "max"
+)
+
+var CompareMetrics = []string{
+	CompareMetricQPS,
+	CompareMetricAvg,
+	CompareMetricP50,
+	CompareMetricP75,
+	CompareMetricP95,
+	CompareMetricP99,
+	CompareMetricP999,
+	CompareMetricMax,
+}
+
+type Comparison struct {
+	Phase   string
+	Metrics []*ComparisonMetric
+}
+
+// Latencies are in milliseconds
+type ComparisonMetric struct {
+	Name       string
+	Baseline   float64
+	Candidate  float64
+	Delta      float64 // Percent change from the baseline
+	Threshold  float64 // Allowed worsening in percent. Zero is unlimited.
+	Regression bool
+}
+
+// Compare the candidate report with the baseline report.
+// "thresholds" is the allowed worsening in percent for each metric,
+// i.e. the decrease of QPS or the increase of latency.
+func CompareReports(base *RecorderReport, cand *RecorderReport, thresholds map[string]float64) *Comparison {
+	cmp := &Comparison{Phase: base.Phase}
+
+	for _, name := range CompareMetrics {
+		m := &ComparisonMetric{
+			Name:      name,
+			Baseline:  compareValue(base, name),
+			Candidate: compareValue(cand, name),
+			Threshold: thresholds[name],
+		}
+
+		if m.Baseline != 0 {
+			m.Delta = (m.Candidate - m.Baseline) / m.Baseline * 100
+		} else if m.Candidate != 0 {
+			m.Delta = math.Inf(1)
+		}
+
+		if m.Threshold > 0 {
+			worsening := m.Delta
+
+			// NOTE: A decrease is a regression for QPS
+			if name == CompareMetricQPS {
+				worsening = -m.Delta
+			}
+
+			m.Regression = worsening > m.Threshold
+		}
+
+		cmp.Metrics = append(cmp.Metrics, m)
+	}
+
+	return cmp
+}
+
+func (cmp *Comparison) Regressed() bool {
+	for _, m := range cmp.Metrics {
+		if m.Regression {
+			return true
+		}
+	}
+
+	return false
+}
+
+func compareValue(rr *RecorderReport, name string) float64 {
+	if name == CompareMetricQPS {
+		return rr.AvgQPS
+	}
+
+	if rr.Response == nil {
+		return 0
+	}
+
+	var d time.Duration
+
+	switch name {
+	case CompareMetricAvg:
+		d = rr.Response.Time.Avg
+	case CompareMetricP50:
+		d = rr.Response.Time.P50
+	case CompareMetricP75:
+		d = rr.Response.Time.P75
+	case CompareMetricP95:
+		d = rr.Response.Time.P95
+	case CompareMetricP99:
+		d = rr.Response.Time.P99
+	case CompareMetricP999:
+		d = rr.Response.Time.P999
+	case CompareMetricMax:
+		d = rr.Response.Time.Max
+	}
+
+	return durationToMs(d)
+}
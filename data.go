@@ -1,13 +1,13 @@
 package qlap
 
 import (
+	"database/sql"
 	"fmt"
 	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/winebarrel/randstr"
 )
 
 type AutoGenerateSqlLoadType string
@@ -16,41 +16,192 @@ const (
 	LoadTypeMixed         = AutoGenerateSqlLoadType("mixed")  // require pre-populated data
 	LoadTypeUpdate        = AutoGenerateSqlLoadType("update") // require pre-populated data
 	LoadTypeWrite         = AutoGenerateSqlLoadType("write")
-	LoadTypeKey           = AutoGenerateSqlLoadType("key")  // require pre-populated data
-	LoadTypeRead          = AutoGenerateSqlLoadType("read") // require pre-populated data
+	LoadTypeKey           = AutoGenerateSqlLoadType("key")    // require pre-populated data
+	LoadTypeRead          = AutoGenerateSqlLoadType("read")   // require pre-populated data
+	LoadTypeDelete        = AutoGenerateSqlLoadType("delete") // require pre-populated data
+	LoadTypeRange         = AutoGenerateSqlLoadType("range")  // require pre-populated data
+	LoadTypeUpsert        = AutoGenerateSqlLoadType("upsert") // require pre-populated data
 	AutoGenerateTableName = "t1"
 )
 
+const (
+	StatementTagSelect   = "select"
+	StatementTagInsert   = "insert"
+	StatementTagUpdate   = "update"
+	StatementTagCommit   = "commit"
+	StatementTagBegin    = "begin"
+	StatementTagRollback = "rollback"
+	StatementTagDelete   = "delete"
+	StatementTagRange    = "range"
+	StatementTagOrdered  = "range_order"
+	StatementTagUpsert   = "upsert"
+	StatementTagReplace  = "replace"
+)
+
+// Tags of the statements that qlap generates, which custom queries cannot be named
+var StatementTags = []string{
+	StatementTagSelect,
+	StatementTagInsert,
+	StatementTagUpdate,
+	StatementTagCommit,
+	StatementTagBegin,
+	StatementTagRollback,
+	StatementTagDelete,
+	StatementTagRange,
+	StatementTagOrdered,
+	StatementTagUpsert,
+	StatementTagReplace,
+}
+
+var (
+	beginStmt    = &statement{tag: StatementTagBegin, sql: "BEGIN", noPrepare: true}
+	commitStmt   = &statement{tag: StatementTagCommit, sql: "COMMIT", noPrepare: true}
+	rollbackStmt = &statement{tag: StatementTagRollback, sql: "ROLLBACK", noPrepare: true}
+)
+
 type DataOpts struct {
 	LoadType               AutoGenerateSqlLoadType
 	GuidPrimary            bool
 	NumberSecondaryIndexes int
+	PreparedStatement      bool
 	CommitRate             int
+	Transaction            bool
+	TransactionRetries     int
 	MixedSelRatio          int
 	MixedInsRatio          int
+	MixRatios              []LoadTypeRatio // Override MixedSelRatio and MixedInsRatio
+	RangeSize              int             // Number of rows of 'range' and 'read' with Table
 	NumberIntCols          int
 	IntColsIndex           bool
 	NumberCharCols         int
 	CharColsIndex          bool
+	Columns                []ColumnSpec // Override NumberIntCols and NumberCharCols
+	Table                  string       // Existing table to generate load against
+	KeyDistribution        KeyDistribution
+	ZipfianTheta           float64  // Skew of 'zipfian' and 'latest'
+	HotspotKeys            float64  // Percentage of hot keys of 'hotspot'
+	HotspotAccess          float64  // Percentage of accesses to hot keys of 'hotspot'
 	Queries                []string `json:"-"`
+	QueryWeights           []int
+	QueryNames             []string
 	PreQueries             []string
 }
 
+// Load type and its ratio in the mixed load
+type LoadTypeRatio struct {
+	LoadType AutoGenerateSqlLoadType
+	Ratio    int
+}
+
+type statement struct {
+	tag         string
+	sql         string
+	args        []interface{} // Bound arguments of the prepared statement
+	returnsRows bool
+	noPrepare   bool                 // Always use the text protocol
+	afterExec   func(res sql.Result) // Called after the statement succeeds
+}
+
+// Build a statement that embeds values inline,
+// or uses placeholders and bound arguments for the prepared statement
+type stmtBuilder struct {
+	strings.Builder
+	tag      string
+	prepared bool
+	args     []interface{}
+}
+
+func (data *Data) newStmtBuilder(tag string) *stmtBuilder {
+	return &stmtBuilder{
+		tag:      tag,
+		prepared: data.PreparedStatement,
+	}
+}
+
+func (sb *stmtBuilder) writeValue(v interface{}) {
+	if sb.prepared {
+		sb.WriteString("?")
+		sb.args = append(sb.args, v)
+	} else {
+		sb.WriteString(sqlLiteral(v))
+	}
+}
+
+func (sb *stmtBuilder) statement() *statement {
+	return &statement{
+		tag:         sb.tag,
+		sql:         sb.String(),
+		args:        sb.args,
+		returnsRows: sb.tag == StatementTagSelect || sb.tag == StatementTagRange || sb.tag == StatementTagOrdered,
+	}
+}
+
 type Data struct {
 	*DataOpts
-	randSrc   rand.Source
-	idList    []string
-	idIdx     int
-	mixedIdx  int
-	commitCnt int
-	queryIdx  int
+	randSrc    rand.Source
+	rnd        *rand.Rand
+	templates  []*queryTemplate
+	columns    []*column
+	indexed    []*column // Columns for the ordered range scan
+	mixRatios  []LoadTypeRatio
+	table      *tableSchema
+	cumWeights []int
+	idList     []string
+	idIdx      int
+	zipf       *zipfian
+	latest     *latestKeys
+	mixedIdx   int
+	rangeIdx   int
+	upsertIdx  int
+	reinsert   bool
+	reinsertId string // Key deleted by the previous statement
+	commitCnt  int
+	queryIdx   int
 }
 
-func newData(opts *DataOpts, idList []string) (data *Data) {
+func newData(opts *DataOpts, idList []string, latest *latestKeys, templates []*queryTemplate, table *tableSchema) (data *Data) {
+	randSrc := rand.NewSource(time.Now().UnixNano())
+
 	data = &Data{
-		DataOpts: opts,
-		randSrc:  rand.NewSource(time.Now().UnixNano()),
-		idList:   idList,
+		DataOpts:  opts,
+		randSrc:   randSrc,
+		rnd:       rand.New(randSrc),
+		templates: templates,
+		columns:   newColumns(opts.columnSpecs()),
+		table:     table,
+		idList:    idList,
+		latest:    latest,
+	}
+
+	if table != nil {
+		data.indexed = table.indexed
+	} else {
+		for _, col := range data.columns {
+			// NOTE: TEXT and BLOB columns have prefix indexes that cannot be used for sorting
+			if col.spec.Index && col.spec.Type != ColumnTypeText && col.spec.Type != ColumnTypeBlob {
+				data.indexed = append(data.indexed, col)
+			}
+		}
+	}
+
+	data.mixRatios = opts.MixRatios
+
+	if len(data.mixRatios) == 0 {
+		data.mixRatios = []LoadTypeRatio{{LoadTypeKey, opts.MixedSelRatio}, {LoadTypeWrite, opts.MixedInsRatio}}
+	}
+
+	if opts.KeyDistribution == KeyDistributionZipfian {
+		data.zipf = newZipfian(len(idList), opts.ZipfianTheta)
+	}
+
+	if len(opts.QueryWeights) > 0 {
+		data.cumWeights = make([]int, len(opts.QueryWeights))
+		sum := 0
+
+		for i, w := range opts.QueryWeights {
+			sum += w
+			data.cumWeights[i] = sum
+		}
 	}
 
 	return
@@ -72,43 +223,60 @@ func (data *Data) initStmts() []string {
 	return stmts
 }
 
-func (data *Data) next() string {
+func (data *Data) next() *statement {
 	if data.CommitRate > 0 {
 		if data.commitCnt == data.CommitRate {
 			data.commitCnt = 0
-			return "COMMIT"
+			return commitStmt
 		}
 
 		data.commitCnt++
 	}
 
 	if len(data.Queries) > 0 {
-		q := data.Queries[data.queryIdx]
-		data.queryIdx++
+		var idx int
 
-		if data.queryIdx == len(data.Queries) {
-			data.queryIdx = 0
+		if len(data.cumWeights) > 0 {
+			idx = data.nextWeightedQueryIdx()
+		} else {
+			idx = data.queryIdx
+			data.queryIdx++
+
+			if data.queryIdx == len(data.Queries) {
+				data.queryIdx = 0
+			}
 		}
 
-		return q
+		return data.buildQueryStmt(idx)
 	}
 
-	switch data.LoadType {
-	case LoadTypeMixed:
-		var stmt string
-		if data.mixedIdx < data.MixedSelRatio {
-			stmt = data.buildSelectStmt(true)
-		} else {
-			stmt = data.buildInsertStmt()
-		}
+	// NOTE: Re-insert the deleted key to keep the table size
+	if data.reinsert {
+		data.reinsert = false
+		return data.buildKeyedInsertStmt(StatementTagInsert, "INSERT IGNORE INTO", data.reinsertId).statement()
+	}
 
-		data.mixedIdx++
+	if data.LoadType == LoadTypeMixed {
+		return data.nextOf(data.nextMixedLoadType())
+	}
 
-		if data.mixedIdx >= data.MixedSelRatio+data.MixedInsRatio {
-			data.mixedIdx = 0
-		}
+	return data.nextOf(data.LoadType)
+}
+
+// Statements to be executed in order on the same connection,
+// e.g. DELETE and the re-insert of the deleted key
+func (data *Data) nextUnit() []*statement {
+	stmts := []*statement{data.next()}
+
+	if data.reinsert {
+		stmts = append(stmts, data.next())
+	}
 
-		return stmt
+	return stmts
+}
+
+func (data *Data) nextOf(loadType AutoGenerateSqlLoadType) *statement {
+	switch loadType {
 	case LoadTypeUpdate:
 		return data.buildUpdateStmt()
 	case LoadTypeWrite:
@@ -117,11 +285,44 @@ func (data *Data) next() string {
 		return data.buildSelectStmt(true)
 	case LoadTypeRead:
 		return data.buildSelectStmt(false)
+	case LoadTypeDelete:
+		return data.buildDeleteStmt()
+	case LoadTypeRange:
+		return data.buildRangeStmt()
+	case LoadTypeUpsert:
+		return data.buildUpsertStmt()
 	default:
-		panic("Failed to generate SQL statement: invalid load type: " + data.LoadType)
+		panic("Failed to generate SQL statement: invalid load type: " + loadType)
 	}
 }
 
+// Cycle through the load types of the mixed load by their ratios,
+// e.g. "key:2,write:1" is key, key, write, key, key, write, ...
+func (data *Data) nextMixedLoadType() AutoGenerateSqlLoadType {
+	idx := data.mixedIdx
+	total := 0
+
+	for _, r := range data.mixRatios {
+		total += r.Ratio
+	}
+
+	data.mixedIdx++
+
+	if data.mixedIdx >= total {
+		data.mixedIdx = 0
+	}
+
+	for _, r := range data.mixRatios {
+		if idx < r.Ratio {
+			return r.LoadType
+		}
+
+		idx -= r.Ratio
+	}
+
+	panic("Failed to generate SQL statement: invalid mix ratios")
+}
+
 func (data *Data) buildCreateTableStmt() string {
 	sb := strings.Builder{}
 	sb.WriteString("CREATE TABLE " + AutoGenerateTableName + " (id ")
@@ -136,19 +337,11 @@ func (data *Data) buildCreateTableStmt() string {
 		fmt.Fprintf(&sb, ",id%d VARCHAR(36) UNIQUE KEY", i)
 	}
 
-	for i := 1; i <= data.NumberIntCols; i++ {
-		fmt.Fprintf(&sb, ",intcol%d INT(32)", i)
+	for _, col := range data.columns {
+		sb.WriteString("," + col.definition())
 
-		if data.IntColsIndex {
-			fmt.Fprintf(&sb, ",INDEX(intcol%d)", i)
-		}
-	}
-
-	for i := 1; i <= data.NumberCharCols; i++ {
-		fmt.Fprintf(&sb, ",charcol%d VARCHAR(128)", i)
-
-		if data.CharColsIndex {
-			fmt.Fprintf(&sb, ",INDEX(charcol%d)", i)
+		if idx := col.index(); idx != "" {
+			sb.WriteString("," + idx)
 		}
 	}
 
@@ -157,37 +350,38 @@ func (data *Data) buildCreateTableStmt() string {
 	return sb.String()
 }
 
-func (data *Data) buildSelectStmt(key bool) string {
-	sb := strings.Builder{}
-	sb.WriteString("SELECT ")
-
-	for i := 1; i <= data.NumberIntCols; i++ {
-		if i >= 2 {
-			sb.WriteString(",")
-		}
-
-		fmt.Fprintf(&sb, "intcol%d", i)
+func (data *Data) buildSelectStmt(key bool) *statement {
+	if data.table != nil {
+		return data.buildTableSelectStmt(key)
 	}
 
-	for i := 1; i <= data.NumberCharCols; i++ {
-		if data.NumberIntCols >= 1 || i >= 2 {
+	sb := data.newStmtBuilder(StatementTagSelect)
+	sb.WriteString("SELECT ")
+
+	for i, col := range data.columns {
+		if i >= 1 {
 			sb.WriteString(",")
 		}
 
-		fmt.Fprintf(&sb, "charcol%d", i)
+		sb.WriteString(col.name)
 	}
 
 	sb.WriteString(" FROM " + AutoGenerateTableName)
 
 	if key {
-		fmt.Fprintf(&sb, " WHERE id = '%s'", data.nextId())
+		sb.WriteString(" WHERE id = ")
+		sb.writeValue(data.nextId())
 	}
 
-	return sb.String()
+	return sb.statement()
 }
 
-func (data *Data) buildInsertStmt() string {
-	sb := strings.Builder{}
+func (data *Data) buildInsertStmt() *statement {
+	if data.table != nil {
+		return data.buildTableInsertStmt()
+	}
+
+	sb := data.newStmtBuilder(StatementTagInsert)
 	sb.WriteString("INSERT INTO " + AutoGenerateTableName + " VALUES (")
 
 	if data.GuidPrimary {
@@ -200,50 +394,254 @@ func (data *Data) buildInsertStmt() string {
 		sb.WriteString(",UUID()")
 	}
 
-	for i := 1; i <= data.NumberIntCols; i++ {
+	for _, col := range data.columns {
 		sb.WriteString(",")
-		num := data.randSrc.Int63() >> 32
-		sb.WriteString(strconv.FormatInt(num, 10))
-	}
-
-	for i := 1; i <= data.NumberCharCols; i++ {
-		sb.WriteString(",'")
-		sb.WriteString(randstr.String(data.randSrc, 128))
-		sb.WriteString("'")
+		sb.writeValue(col.value(data.randSrc))
 	}
 
 	sb.WriteString(")")
+	stmt := sb.statement()
 
-	return sb.String()
+	if data.latest != nil && !data.GuidPrimary {
+		stmt.afterExec = func(res sql.Result) {
+			if id, err := res.LastInsertId(); err == nil && id > 0 {
+				data.latest.add(strconv.FormatInt(id, 10))
+			}
+		}
+	}
+
+	return stmt
 }
 
-func (data *Data) buildUpdateStmt() string {
-	sb := strings.Builder{}
+func (data *Data) buildUpdateStmt() *statement {
+	if data.table != nil {
+		return data.buildTableUpdateStmt()
+	}
+
+	sb := data.newStmtBuilder(StatementTagUpdate)
 	sb.WriteString("UPDATE " + AutoGenerateTableName + " SET ")
 
-	for i := 1; i <= data.NumberIntCols; i++ {
-		if i >= 2 {
+	for i, col := range data.columns {
+		if i >= 1 {
 			sb.WriteString(",")
 		}
 
-		v := data.randSrc.Int63() >> 32
-		fmt.Fprintf(&sb, "intcol%d = %d", i, v)
+		sb.WriteString(col.name + " = ")
+		sb.writeValue(col.value(data.randSrc))
+	}
+
+	sb.WriteString(" WHERE id = ")
+	sb.writeValue(data.nextId())
+
+	return sb.statement()
+}
+
+// Delete a row by the key, and re-insert it with the next statement
+func (data *Data) buildDeleteStmt() *statement {
+	id := data.nextId()
+	sb := data.newStmtBuilder(StatementTagDelete)
+	sb.WriteString("DELETE FROM " + data.tableName() + " WHERE " + data.keyName() + " = ")
+	sb.writeValue(id)
+	data.reinsert = true
+	data.reinsertId = id
+
+	return sb.statement()
+}
+
+// Scan rows by the range of the key,
+// and then in the order of each indexed column
+func (data *Data) buildRangeStmt() *statement {
+	idx := data.rangeIdx
+	data.rangeIdx++
+
+	if data.rangeIdx > len(data.indexed) {
+		data.rangeIdx = 0
+	}
+
+	if idx > 0 {
+		col := data.indexed[idx-1]
+		sb := data.newStmtBuilder(StatementTagOrdered)
+		sb.WriteString("SELECT " + data.selectList() + " FROM " + data.tableName() + " WHERE " + data.columnName(col) + " >= ")
+		sb.writeValue(col.value(data.randSrc))
+		fmt.Fprintf(sb, " ORDER BY %s LIMIT %d", data.columnName(col), data.RangeSize)
+
+		return sb.statement()
 	}
 
-	for i := 1; i <= data.NumberCharCols; i++ {
-		if data.NumberIntCols >= 1 || i >= 2 {
+	id := data.nextId()
+	sb := data.newStmtBuilder(StatementTagRange)
+	sb.WriteString("SELECT " + data.selectList() + " FROM " + data.tableName() + " WHERE " + data.keyName())
+
+	// NOTE: BETWEEN on non-integer keys, e.g. GUID, converts the key of each row
+	if n, err := strconv.ParseInt(id, 10, 64); data.intKey() && err == nil {
+		sb.WriteString(" BETWEEN ")
+		sb.writeValue(n)
+		sb.WriteString(" AND ")
+		sb.writeValue(n + int64(data.RangeSize) - 1)
+	} else {
+		sb.WriteString(" >= ")
+		sb.writeValue(id)
+		fmt.Fprintf(sb, " ORDER BY %s LIMIT %d", data.keyName(), data.RangeSize)
+	}
+
+	return sb.statement()
+}
+
+// Alternate INSERT ... ON DUPLICATE KEY UPDATE and REPLACE of the existing key
+func (data *Data) buildUpsertStmt() *statement {
+	id := data.nextId()
+	data.upsertIdx++
+
+	if data.upsertIdx%2 == 0 {
+		return data.buildKeyedInsertStmt(StatementTagReplace, "REPLACE INTO", id).statement()
+	}
+
+	sb := data.buildKeyedInsertStmt(StatementTagUpsert, "INSERT INTO", id)
+	sb.WriteString(" ON DUPLICATE KEY UPDATE ")
+
+	for i, col := range data.writeColumns() {
+		if i >= 1 {
 			sb.WriteString(",")
 		}
 
-		fmt.Fprintf(&sb, "charcol%d = '%s'", i, randstr.String(data.randSrc, 128))
+		sb.WriteString(data.columnName(col) + " = ")
+		sb.writeValue(col.value(data.randSrc))
 	}
 
-	fmt.Fprintf(&sb, " WHERE id = '%s'", data.nextId())
+	return sb.statement()
+}
 
-	return sb.String()
+// Insert a row with the key, e.g. "REPLACE INTO t1 VALUES (...)"
+func (data *Data) buildKeyedInsertStmt(tag string, verb string, id string) *stmtBuilder {
+	if data.table != nil {
+		return data.buildTableKeyedInsertStmt(tag, verb, id)
+	}
+
+	sb := data.newStmtBuilder(tag)
+	sb.WriteString(verb + " " + AutoGenerateTableName + " VALUES (")
+	sb.writeValue(id)
+
+	for i := 1; i <= data.NumberSecondaryIndexes; i++ {
+		sb.WriteString(",UUID()")
+	}
+
+	for _, col := range data.columns {
+		sb.WriteString(",")
+		sb.writeValue(col.value(data.randSrc))
+	}
+
+	sb.WriteString(")")
+
+	return sb
+}
+
+func (data *Data) tableName() string {
+	if data.table != nil {
+		return quoteIdentifier(data.table.name)
+	}
+
+	return AutoGenerateTableName
+}
+
+func (data *Data) keyName() string {
+	if data.table != nil {
+		return quoteIdentifier(data.table.primaryKey.name)
+	}
+
+	return "id"
+}
+
+// Whether the key is an integer column
+func (data *Data) intKey() bool {
+	if data.table != nil {
+		spec := data.table.primaryKey.spec
+		return spec != nil && (spec.Type == ColumnTypeInt || spec.Type == ColumnTypeBigint)
+	}
+
+	return !data.GuidPrimary
+}
+
+func (data *Data) columnName(col *column) string {
+	if data.table != nil {
+		return quoteIdentifier(col.name)
+	}
+
+	return col.name
+}
+
+// Columns to be written, except for the key
+func (data *Data) writeColumns() []*column {
+	if data.table != nil {
+		return data.table.columns
+	}
+
+	return data.columns
+}
+
+func (data *Data) selectList() string {
+	if data.table != nil {
+		return data.table.selectList
+	}
+
+	names := make([]string, len(data.columns))
+
+	for i, col := range data.columns {
+		names[i] = col.name
+	}
+
+	return strings.Join(names, ",")
+}
+
+// All custom queries enclosed in BEGIN and COMMIT
+func (data *Data) transaction() []*statement {
+	stmts := []*statement{beginStmt}
+
+	for i := range data.Queries {
+		stmts = append(stmts, data.buildQueryStmt(i))
+	}
+
+	return append(stmts, commitStmt)
+}
+
+func (data *Data) buildQueryStmt(idx int) *statement {
+	q, args := data.templates[idx].render(data.rnd, data.PreparedStatement)
+
+	return &statement{
+		tag:         data.queryTag(idx),
+		sql:         q,
+		args:        args,
+		returnsRows: data.templates[idx].returnsRows,
+	}
+}
+
+// Choose a query randomly by weight
+func (data *Data) nextWeightedQueryIdx() int {
+	r := data.rnd.Intn(data.cumWeights[len(data.cumWeights)-1])
+
+	return sort.Search(len(data.cumWeights), func(i int) bool {
+		return data.cumWeights[i] > r
+	})
+}
+
+// Tag of a custom query: the query name or the index, e.g. "query#0"
+func (data *Data) queryTag(idx int) string {
+	if len(data.QueryNames) > 0 {
+		return data.QueryNames[idx]
+	}
+
+	return "query#" + strconv.Itoa(idx)
 }
 
 func (data *Data) nextId() string {
+	switch data.KeyDistribution {
+	case KeyDistributionZipfian:
+		return data.idList[data.zipf.next(data.rnd)]
+	case KeyDistributionHotspot:
+		return data.idList[data.nextHotspotIdx()]
+	case KeyDistributionLatest:
+		return data.latest.next(data.rnd)
+	}
+
 	if data.idIdx >= len(data.idList) {
 		data.idIdx = 0
 	}
@@ -0,0 +1,299 @@
+package qlap
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/winebarrel/tachymeter"
+)
+
+const (
+	DefaultHistogramPrecision = 3
+	MaxHistogramPrecision     = 3 // Memory usage grows tenfold with each digit
+	HistogramBins             = 10
+)
+
+// Log-linear histogram of durations like HdrHistogram.
+// Values are recorded with the relative error of 10^-precision,
+// and the memory size does not depend on the number of values.
+type histogram struct {
+	subBucketBits uint
+	counts        []uint64
+	count         uint64
+	sum           time.Duration
+	sumSq         float64
+	invSum        float64 // For the harmonic mean
+	min           time.Duration
+	max           time.Duration
+}
+
+func newHistogram(precision int) *histogram {
+	// Number of sub-buckets in each power-of-two range must be >= 2 * 10^precision
+	subBucketCnt := 2 * int64(math.Pow10(precision))
+
+	return &histogram{
+		subBucketBits: uint(bits.Len64(uint64(subBucketCnt - 1))),
+	}
+}
+
+func (h *histogram) index(v int64) int {
+	bucket := bits.Len64(uint64(v)) - int(h.subBucketBits)
+
+	if bucket <= 0 {
+		return int(v)
+	}
+
+	half := 1 << (h.subBucketBits - 1)
+
+	return bucket*half + int(v>>uint(bucket))
+}
+
+// Lowest and highest values of the bucket
+func (h *histogram) valueRange(idx int) (time.Duration, time.Duration) {
+	cnt := 1 << h.subBucketBits
+
+	if idx < cnt {
+		return time.Duration(idx), time.Duration(idx)
+	}
+
+	half := cnt / 2
+	bucket := idx/half - 1
+	sub := int64(idx - bucket*half)
+
+	return time.Duration(sub << uint(bucket)), time.Duration((sub+1)<<uint(bucket) - 1)
+}
+
+func (h *histogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	idx := h.index(int64(d))
+
+	if idx >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, idx+1-len(h.counts))...)
+	}
+
+	h.counts[idx]++
+
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+
+	if d > h.max {
+		h.max = d
+	}
+
+	h.count++
+	h.sum += d
+	h.sumSq += float64(d) * float64(d)
+
+	if d > 0 {
+		h.invSum += 1 / float64(d)
+	}
+}
+
+// NOTE: Both histograms must have the same precision
+func (h *histogram) merge(other *histogram) {
+	if other.count == 0 {
+		return
+	}
+
+	if len(other.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, len(other.counts)-len(h.counts))...)
+	}
+
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+
+	if other.max > h.max {
+		h.max = other.max
+	}
+
+	h.count += other.count
+	h.sum += other.sum
+	h.sumSq += other.sumSq
+	h.invSum += other.invSum
+}
+
+// Representative value of the bucket within [min, max]
+func (h *histogram) value(idx int) time.Duration {
+	low, high := h.valueRange(idx)
+	v := low + (high-low)/2
+
+	if v < h.min {
+		v = h.min
+	} else if v > h.max {
+		v = h.max
+	}
+
+	return v
+}
+
+// Value of the given rank (1-based)
+func (h *histogram) valueAtRank(rank uint64) time.Duration {
+	var cum uint64
+
+	for i, c := range h.counts {
+		cum += c
+
+		if cum >= rank {
+			return h.value(i)
+		}
+	}
+
+	return h.max
+}
+
+// Same rank as tachymeter
+func (h *histogram) percentile(p float64) time.Duration {
+	rank := uint64(float64(h.count)*p + 0.5)
+
+	if rank < 1 {
+		rank = 1
+	}
+
+	return h.valueAtRank(rank)
+}
+
+// Average of the "n" shortest (or longest) values
+func (h *histogram) avgOf(n uint64, longest bool) time.Duration {
+	var total float64
+	left := n
+
+	for i := range h.counts {
+		if longest {
+			i = len(h.counts) - 1 - i
+		}
+
+		c := h.counts[i]
+
+		if c == 0 {
+			continue
+		}
+
+		if c > left {
+			c = left
+		}
+
+		total += float64(h.value(i)) * float64(c)
+		left -= c
+
+		if left == 0 {
+			break
+		}
+	}
+
+	return time.Duration(total / float64(n))
+}
+
+// Calculate the same metrics as tachymeter to keep the report compatible
+func (h *histogram) metrics(hInterval time.Duration) *tachymeter.Metrics {
+	m := &tachymeter.Metrics{}
+
+	if h.count == 0 {
+		return m
+	}
+
+	n := int(h.count)
+	m.Samples = n
+	m.Count = n
+	m.Time.Cumulative = h.sum
+	m.Time.Avg = time.Duration(int64(h.sum) / int64(n))
+
+	if h.invSum > 0 {
+		m.Time.HMean = time.Duration(float64(n) / h.invSum)
+	}
+
+	if h.sum > 0 {
+		m.Rate.Second = float64(n) / float64(h.sum) * 1e9
+	}
+
+	m.Time.P50 = h.valueAtRank(uint64(n/2) + 1)
+	m.Time.P75 = h.percentile(0.75)
+	m.Time.P95 = h.percentile(0.95)
+	m.Time.P99 = h.percentile(0.99)
+	m.Time.P999 = h.percentile(0.999)
+
+	if long := n - int(float64(n)*0.95+0.5); long <= 1 {
+		m.Time.Long5p = h.max
+	} else {
+		m.Time.Long5p = h.avgOf(uint64(long), true)
+	}
+
+	if short := int(float64(n)*0.05 + 0.5); short <= 1 {
+		m.Time.Short5p = h.min
+	} else {
+		m.Time.Short5p = h.avgOf(uint64(short), false)
+	}
+
+	m.Time.Min = h.min
+	m.Time.Max = h.max
+	m.Time.Range = h.max - h.min
+	avg := float64(h.sum) / float64(n)
+	variance := h.sumSq/float64(n) - avg*avg
+
+	if variance > 0 {
+		m.Time.StdDev = time.Duration(math.Sqrt(variance))
+	}
+
+	if hInterval > 0 {
+		m.Histogram, m.HistogramBinSize = h.bins(HistogramBins, hInterval, 0)
+	} else {
+		m.Histogram, m.HistogramBinSize = h.bins(HistogramBins, time.Duration(int64(m.Time.Range)/HistogramBins), h.min)
+	}
+
+	return m
+}
+
+// Frequency distribution in the same format as tachymeter.
+// The last bin includes all values up to the max.
+func (h *histogram) bins(b int, interval time.Duration, low time.Duration) (*tachymeter.Histogram, time.Duration) {
+	counts := make([]uint64, b)
+
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+
+		pos := 0
+
+		if v := h.value(i); interval > 0 && v > low+interval {
+			pos = int((v - low - 1) / interval)
+		}
+
+		if pos >= b {
+			pos = b - 1
+		}
+
+		counts[pos] += c
+	}
+
+	res := time.Duration(1000)
+	hgram := make(tachymeter.Histogram, b)
+
+	for i := 0; i < b; i++ {
+		binLow := low + time.Duration(i)*interval
+		binHigh := binLow + interval
+
+		if i > 0 {
+			binLow += time.Nanosecond
+		}
+
+		if i == b-1 {
+			binHigh = h.max
+		}
+
+		label := fmt.Sprintf("%s - %s", binLow/res*res, binHigh/res*res)
+		hgram[i] = map[string]uint64{label: counts[i]}
+	}
+
+	return &hgram, interval
+}
@@ -0,0 +1,93 @@
+package qlap
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistogramIndexAndValueRange(t *testing.T) {
+	for precision := 1; precision <= MaxHistogramPrecision; precision++ {
+		h := newHistogram(precision)
+		cnt := int64(1) << h.subBucketBits
+		maxErr := math.Pow10(-precision)
+
+		values := []int64{0, 1, cnt - 1, cnt, cnt + 1, 2*cnt - 1, 2 * cnt, 2*cnt + 1, int64(time.Millisecond), int64(time.Second), int64(time.Hour)}
+
+		for shift := uint(1); shift < 50; shift++ {
+			values = append(values, (1<<shift)-1, 1<<shift, (1<<shift)+1)
+		}
+
+		for _, v := range values {
+			idx := h.index(v)
+			low, high := h.valueRange(idx)
+
+			if int64(low) > v || v > int64(high) {
+				t.Errorf("precision=%d v=%d: not in [%d, %d] of index %d", precision, v, low, high, idx)
+			}
+
+			if h.index(int64(low)) != idx || h.index(int64(high)) != idx {
+				t.Errorf("precision=%d v=%d: edges [%d, %d] are not in index %d", precision, v, low, high, idx)
+			}
+
+			if h.index(int64(high)+1) != idx+1 {
+				t.Errorf("precision=%d v=%d: next value of %d is not in index %d", precision, v, high, idx+1)
+			}
+
+			if v > 0 && float64(high-low)/float64(v) > maxErr {
+				t.Errorf("precision=%d v=%d: bucket [%d, %d] is wider than the relative error %g", precision, v, low, high, maxErr)
+			}
+		}
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0.5, 5000 * time.Microsecond},
+		{0.95, 9500 * time.Microsecond},
+		{0.99, 9900 * time.Microsecond},
+		{0.999, 9990 * time.Microsecond},
+		{1, 10000 * time.Microsecond},
+	}
+
+	for precision := 1; precision <= MaxHistogramPrecision; precision++ {
+		h := newHistogram(precision)
+
+		for i := 1; i <= 10000; i++ {
+			h.record(time.Duration(i) * time.Microsecond)
+		}
+
+		maxErr := math.Pow10(-precision)
+
+		for _, tt := range tests {
+			got := h.percentile(tt.p)
+
+			if math.Abs(float64(got-tt.want))/float64(tt.want) > maxErr {
+				t.Errorf("precision=%d p=%g: got %s, want %s", precision, tt.p, got, tt.want)
+			}
+		}
+
+		if h.min != time.Microsecond || h.max != 10000*time.Microsecond {
+			t.Errorf("precision=%d: min/max = %s/%s", precision, h.min, h.max)
+		}
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	h1 := newHistogram(DefaultHistogramPrecision)
+	h2 := newHistogram(DefaultHistogramPrecision)
+	h1.record(time.Millisecond)
+	h2.record(time.Second)
+	h1.merge(h2)
+
+	if h1.count != 2 || h1.min != time.Millisecond || h1.max != time.Second {
+		t.Errorf("count/min/max = %d/%s/%s", h1.count, h1.min, h1.max)
+	}
+
+	if got := h1.valueAtRank(2); got != time.Second {
+		t.Errorf("valueAtRank(2) = %s, want %s", got, time.Second)
+	}
+}
@@ -0,0 +1,146 @@
+package qlap
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+type KeyDistribution string
+
+const (
+	KeyDistributionUniform = KeyDistribution("uniform")
+	KeyDistributionZipfian = KeyDistribution("zipfian")
+	KeyDistributionHotspot = KeyDistribution("hotspot")
+	KeyDistributionLatest  = KeyDistribution("latest")
+)
+
+const (
+	DefaultZipfianTheta  = 0.99
+	DefaultHotspotKeys   = 20.0
+	DefaultHotspotAccess = 80.0
+	latestMinKeys        = 1000
+)
+
+// Generate ranks in [0, n) following the Zipfian distribution.
+// See "Quickly Generating Billion-Record Synthetic Databases" by Gray et al.
+type zipfian struct {
+	theta float64
+	alpha float64
+	zeta2 float64
+	zetan float64
+	eta   float64
+	n     int
+}
+
+func newZipfian(n int, theta float64) *zipfian {
+	z := &zipfian{
+		theta: theta,
+		alpha: 1 / (1 - theta),
+		zeta2: 1 + math.Pow(0.5, theta),
+	}
+
+	z.resize(n)
+
+	return z
+}
+
+// Add the terms of the new items to zeta instead of recomputing it
+func (z *zipfian) resize(n int) {
+	for i := z.n + 1; i <= n; i++ {
+		z.zetan += 1 / math.Pow(float64(i), z.theta)
+	}
+
+	z.n = n
+
+	if n < 1 {
+		return
+	}
+
+	z.eta = (1 - math.Pow(2/float64(n), 1-z.theta)) / (1 - z.zeta2/z.zetan)
+}
+
+func (z *zipfian) next(rnd *rand.Rand) int {
+	u := rnd.Float64()
+	uz := u * z.zetan
+
+	if uz < 1 {
+		return 0
+	} else if uz < z.zeta2 && z.n > 1 {
+		return 1
+	}
+
+	rank := int(float64(z.n) * math.Pow(z.eta*u-z.eta+1, z.alpha))
+
+	if rank >= z.n {
+		rank = z.n - 1
+	}
+
+	return rank
+}
+
+// Keys in the order of insertion.
+// When it is full, a new key overwrites the oldest one.
+type latestKeys struct {
+	sync.Mutex
+	keys []string
+	head int // Index of the next key
+	size int
+	zipf *zipfian
+}
+
+func newLatestKeys(keys []string, theta float64) *latestKeys {
+	size := len(keys)
+
+	if size < latestMinKeys {
+		size = latestMinKeys
+	}
+
+	lk := &latestKeys{
+		keys: make([]string, len(keys), size),
+		head: len(keys) % size,
+		size: size,
+		zipf: newZipfian(len(keys), theta),
+	}
+
+	copy(lk.keys, keys)
+
+	return lk
+}
+
+func (lk *latestKeys) add(key string) {
+	lk.Lock()
+	defer lk.Unlock()
+
+	if len(lk.keys) < lk.size {
+		lk.keys = append(lk.keys, key)
+		lk.zipf.resize(len(lk.keys))
+	} else {
+		lk.keys[lk.head] = key
+	}
+
+	lk.head = (lk.head + 1) % lk.size
+}
+
+// The newer the key, the more frequently it is chosen
+func (lk *latestKeys) next(rnd *rand.Rand) string {
+	lk.Lock()
+	defer lk.Unlock()
+
+	rank := lk.zipf.next(rnd)
+	n := len(lk.keys)
+
+	return lk.keys[((lk.head-1-rank)%n+n)%n]
+}
+
+// Index of the key: HotspotKeys% of keys get HotspotAccess% of accesses
+func (data *Data) nextHotspotIdx() int {
+	n := len(data.idList)
+	hot := int(math.Ceil(float64(n) * data.HotspotKeys / 100))
+
+	if hot >= n || data.rnd.Float64()*100 < data.HotspotAccess {
+		return data.rnd.Intn(hot)
+	}
+
+	return hot + data.rnd.Intn(n-hot)
+}
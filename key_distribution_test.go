@@ -0,0 +1,147 @@
+package qlap
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+const distTrials = 200000
+
+func TestZipfian(t *testing.T) {
+	n := 1000
+	rnd := rand.New(rand.NewSource(1))
+	z := newZipfian(n, DefaultZipfianTheta)
+	counts := make([]int, n)
+
+	for i := 0; i < distTrials; i++ {
+		rank := z.next(rnd)
+
+		if rank < 0 || rank >= n {
+			t.Fatalf("rank %d is out of [0, %d)", rank, n)
+		}
+
+		counts[rank]++
+	}
+
+	// P(rank) = 1 / (rank+1)^theta / zeta(n, theta)
+	for rank := 0; rank < 2; rank++ {
+		want := 1 / math.Pow(float64(rank+1), DefaultZipfianTheta) / z.zetan
+		got := float64(counts[rank]) / distTrials
+
+		if math.Abs(got-want) > 0.005 {
+			t.Errorf("frequency of rank %d = %.4f, want %.4f", rank, got, want)
+		}
+	}
+
+	if counts[0] <= counts[1] || counts[1] <= counts[10] || counts[10] <= counts[100] {
+		t.Errorf("frequencies do not decrease: %d, %d, %d, %d", counts[0], counts[1], counts[10], counts[100])
+	}
+}
+
+func TestZipfianResize(t *testing.T) {
+	z := newZipfian(10, DefaultZipfianTheta)
+	z.resize(1000)
+
+	if want := newZipfian(1000, DefaultZipfianTheta); math.Abs(z.zetan-want.zetan) > 1e-9 || math.Abs(z.eta-want.eta) > 1e-9 {
+		t.Errorf("zetan/eta = %g/%g, want %g/%g", z.zetan, z.eta, want.zetan, want.eta)
+	}
+}
+
+func TestNextHotspotIdx(t *testing.T) {
+	n := 1000
+	data := &Data{
+		DataOpts: &DataOpts{HotspotKeys: DefaultHotspotKeys, HotspotAccess: DefaultHotspotAccess},
+		rnd:      rand.New(rand.NewSource(1)),
+		idList:   make([]string, n),
+	}
+
+	hot := int(float64(n) * DefaultHotspotKeys / 100)
+	hotCnt := 0
+	coldLow := 0
+
+	for i := 0; i < distTrials; i++ {
+		idx := data.nextHotspotIdx()
+
+		if idx < 0 || idx >= n {
+			t.Fatalf("index %d is out of [0, %d)", idx, n)
+		}
+
+		if idx < hot {
+			hotCnt++
+		} else if idx < hot+(n-hot)/2 {
+			coldLow++
+		}
+	}
+
+	if got := float64(hotCnt) / distTrials * 100; math.Abs(got-DefaultHotspotAccess) > 0.5 {
+		t.Errorf("accesses to hot keys = %.2f%%, want %.2f%%", got, DefaultHotspotAccess)
+	}
+
+	// Cold keys are accessed uniformly
+	if got := float64(coldLow) / float64(distTrials-hotCnt); math.Abs(got-0.5) > 0.01 {
+		t.Errorf("accesses to the first half of cold keys = %.4f, want 0.5", got)
+	}
+}
+
+func TestLatestKeys(t *testing.T) {
+	keys := make([]string, latestMinKeys)
+
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	lk := newLatestKeys(keys, DefaultZipfianTheta)
+
+	// The ring is full, so new keys overwrite the oldest ones
+	for i := latestMinKeys; i < latestMinKeys+5; i++ {
+		lk.add(strconv.Itoa(i))
+	}
+
+	if len(lk.keys) != latestMinKeys || lk.head != 5 {
+		t.Fatalf("len/head = %d/%d, want %d/5", len(lk.keys), lk.head, latestMinKeys)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	counts := map[string]int{}
+
+	for i := 0; i < distTrials; i++ {
+		counts[lk.next(rnd)]++
+	}
+
+	for i := 0; i < 5; i++ {
+		if key := strconv.Itoa(i); counts[key] > 0 {
+			t.Errorf("overwritten key %s is accessed", key)
+		}
+	}
+
+	newest := strconv.Itoa(latestMinKeys + 4)
+	second := strconv.Itoa(latestMinKeys + 3)
+	oldest := strconv.Itoa(5)
+
+	if counts[newest] <= counts[second] || counts[second] <= counts[oldest] {
+		t.Errorf("accesses to newest/second/oldest = %d/%d/%d", counts[newest], counts[second], counts[oldest])
+	}
+}
+
+func TestLatestKeysGrow(t *testing.T) {
+	lk := newLatestKeys([]string{"1"}, DefaultZipfianTheta)
+	lk.add("2")
+	lk.add("3")
+
+	if len(lk.keys) != 3 || lk.head != 3 || lk.zipf.n != 3 {
+		t.Fatalf("len/head/n = %d/%d/%d, want 3/3/3", len(lk.keys), lk.head, lk.zipf.n)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	counts := map[string]int{}
+
+	for i := 0; i < distTrials; i++ {
+		counts[lk.next(rnd)]++
+	}
+
+	if counts["3"] <= counts["2"] || counts["2"] <= counts["1"] {
+		t.Errorf("accesses to 3/2/1 = %d/%d/%d", counts["3"], counts["2"], counts["1"])
+	}
+}
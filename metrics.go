@@ -0,0 +1,198 @@
+package qlap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Upper bounds of the latency histogram (sec)
+var MetricsLatencyBuckets = []float64{
+	0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+type metricsKey struct {
+	agentId int
+	tag     string
+}
+
+type metricsSeries struct {
+	queryCnt uint64
+	errCnt   uint64
+	buckets  []uint64
+	sum      float64
+}
+
+type Metrics struct {
+	sync.Mutex
+	activeAgents int32
+	series       map[metricsKey]*metricsSeries
+	targetRates  map[int]int
+	server       *http.Server
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		series:      map[metricsKey]*metricsSeries{},
+		targetRates: map[int]int{},
+	}
+}
+
+func (m *Metrics) add(recDps []recorderDataPoint) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, v := range recDps {
+		key := metricsKey{agentId: v.agentId, tag: v.tag}
+		s, ok := m.series[key]
+
+		if !ok {
+			s = &metricsSeries{buckets: make([]uint64, len(MetricsLatencyBuckets))}
+			m.series[key] = s
+		}
+
+		if v.err != nil {
+			s.errCnt++
+			continue
+		}
+
+		s.queryCnt++
+		sec := v.resTime.Seconds()
+		s.sum += sec
+
+		for i, le := range MetricsLatencyBuckets {
+			if sec <= le {
+				s.buckets[i]++
+			}
+		}
+	}
+}
+
+func (m *Metrics) agentStarted(agentId int, rate int) {
+	atomic.AddInt32(&m.activeAgents, 1)
+	m.setTargetRate(agentId, rate)
+}
+
+func (m *Metrics) agentFinished(agentId int) {
+	atomic.AddInt32(&m.activeAgents, -1)
+}
+
+func (m *Metrics) setTargetRate(agentId int, rate int) {
+	m.Lock()
+	defer m.Unlock()
+	m.targetRates[agentId] = rate
+}
+
+func (m *Metrics) listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.write(w)
+	})
+
+	m.server = &http.Server{Handler: mux}
+
+	go func() {
+		_ = m.server.Serve(ln)
+	}()
+
+	return nil
+}
+
+func (m *Metrics) shutdown() error {
+	if m.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.server.Shutdown(ctx)
+}
+
+// Write metrics in the Prometheus text exposition format
+func (m *Metrics) write(w io.Writer) {
+	m.Lock()
+	defer m.Unlock()
+
+	keys := make([]metricsKey, 0, len(m.series))
+
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].agentId != keys[j].agentId {
+			return keys[i].agentId < keys[j].agentId
+		}
+
+		return keys[i].tag < keys[j].tag
+	})
+
+	fmt.Fprintln(w, "# HELP qlap_queries_total Number of queries executed successfully.")
+	fmt.Fprintln(w, "# TYPE qlap_queries_total counter")
+
+	for _, k := range keys {
+		fmt.Fprintf(w, "qlap_queries_total{%s} %d\n", k.labels(), m.series[k].queryCnt)
+	}
+
+	fmt.Fprintln(w, "# HELP qlap_errors_total Number of queries that failed.")
+	fmt.Fprintln(w, "# TYPE qlap_errors_total counter")
+
+	for _, k := range keys {
+		fmt.Fprintf(w, "qlap_errors_total{%s} %d\n", k.labels(), m.series[k].errCnt)
+	}
+
+	fmt.Fprintln(w, "# HELP qlap_query_duration_seconds Response time of queries.")
+	fmt.Fprintln(w, "# TYPE qlap_query_duration_seconds histogram")
+
+	for _, k := range keys {
+		s := m.series[k]
+		labels := k.labels()
+
+		for i, le := range MetricsLatencyBuckets {
+			fmt.Fprintf(w, "qlap_query_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, strconv.FormatFloat(le, 'f', -1, 64), s.buckets[i])
+		}
+
+		fmt.Fprintf(w, "qlap_query_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, s.queryCnt)
+		fmt.Fprintf(w, "qlap_query_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(s.sum, 'f', -1, 64))
+		fmt.Fprintf(w, "qlap_query_duration_seconds_count{%s} %d\n", labels, s.queryCnt)
+	}
+
+	fmt.Fprintln(w, "# HELP qlap_active_agents Number of running agents.")
+	fmt.Fprintln(w, "# TYPE qlap_active_agents gauge")
+	fmt.Fprintf(w, "qlap_active_agents %d\n", atomic.LoadInt32(&m.activeAgents))
+
+	agentIds := make([]int, 0, len(m.targetRates))
+
+	for id := range m.targetRates {
+		agentIds = append(agentIds, id)
+	}
+
+	sort.Ints(agentIds)
+
+	fmt.Fprintln(w, "# HELP qlap_target_rate Rate limit for each agent (qps). Zero is unlimited.")
+	fmt.Fprintln(w, "# TYPE qlap_target_rate gauge")
+
+	for _, id := range agentIds {
+		fmt.Fprintf(w, "qlap_target_rate{agent=\"%d\"} %d\n", id, m.targetRates[id])
+	}
+}
+
+func (k metricsKey) labels() string {
+	return fmt.Sprintf("agent=\"%d\",statement=%s", k.agentId, strconv.Quote(k.tag))
+}
@@ -3,6 +3,8 @@ package qlap
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"sync/atomic"
 
 	"github.com/go-sql-driver/mysql"
 )
@@ -15,7 +17,9 @@ type MysqlConfig struct {
 type DB interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
 	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
 	Close() error
 }
@@ -25,14 +29,12 @@ func (myCfg *MysqlConfig) openAndPing(maxIdleConns int) (DB, error) {
 		return &NullDB{}, nil
 	}
 
-	dsn := myCfg.FormatDSN()
-	db, err := sql.Open("mysql", dsn)
+	db, err := myCfg.open()
 
 	if err != nil {
 		return nil, err
 	}
 
-	db.SetConnMaxLifetime(0)
 	db.SetMaxIdleConns(0)
 
 	err = db.Ping()
@@ -45,3 +47,66 @@ func (myCfg *MysqlConfig) openAndPing(maxIdleConns int) (DB, error) {
 
 	return db, nil
 }
+
+// Open a new DB and keep the connection established by ping
+func (myCfg *MysqlConfig) connect(ctx context.Context, maxIdleConns int) (DB, error) {
+	if myCfg.OnlyPrint {
+		return &NullDB{}, nil
+	}
+
+	db, err := myCfg.open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxIdleConns(maxIdleConns)
+
+	err = db.PingContext(ctx)
+
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (myCfg *MysqlConfig) open() (*countingDB, error) {
+	connector, err := mysql.NewConnector(myCfg.Config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cc := &countingConnector{Connector: connector}
+	db := sql.OpenDB(cc)
+	db.SetConnMaxLifetime(0)
+
+	return &countingDB{DB: db, connector: cc}, nil
+}
+
+// Count connections opened by the pool to report reconnects
+type countingConnector struct {
+	driver.Connector
+	connectCnt int64
+}
+
+func (cc *countingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := cc.Connector.Connect(ctx)
+
+	if err == nil {
+		atomic.AddInt64(&cc.connectCnt, 1)
+	}
+
+	return conn, err
+}
+
+type countingDB struct {
+	*sql.DB
+	connector *countingConnector
+}
+
+func (db *countingDB) connectCount() int {
+	return int(atomic.LoadInt64(&db.connector.connectCnt))
+}
@@ -10,12 +10,16 @@ import (
 type NullDB struct{}
 
 func (db *NullDB) Exec(query string, args ...interface{}) (sql.Result, error) {
-	fmt.Fprintln(os.Stderr, query)
+	printQuery(query, args)
 	return nil, nil
 }
 
 func (db *NullDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	fmt.Fprintln(os.Stderr, query)
+	printQuery(query, args)
+	return nil, nil
+}
+
+func (db *NullDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
 	return nil, nil
 }
 
@@ -24,6 +28,11 @@ func (db *NullDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	return &sql.Rows{}, nil
 }
 
+func (db *NullDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	printQuery(query, args)
+	return &sql.Rows{}, nil
+}
+
 func (db *NullDB) QueryRow(query string, args ...interface{}) *sql.Row {
 	fmt.Fprintln(os.Stderr, query)
 	return &sql.Row{}
@@ -32,3 +41,11 @@ func (db *NullDB) QueryRow(query string, args ...interface{}) *sql.Row {
 func (db *NullDB) Close() error {
 	return nil
 }
+
+func printQuery(query string, args []interface{}) {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, query, args)
+	} else {
+		fmt.Fprintln(os.Stderr, query)
+	}
+}
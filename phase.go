@@ -0,0 +1,143 @@
+package qlap
+
+import (
+	"time"
+)
+
+// Settings that can be changed for each phase
+type Phase struct {
+	Name                   string
+	NAgents                int
+	Time                   time.Duration `json:"-"`
+	NumberQueriesToExecute int
+	Rate                   int
+	RateStages             []RateStage
+	WarmUp                 time.Duration `json:"-"`
+	LoadType               AutoGenerateSqlLoadType
+}
+
+type PhasesReport struct {
+	Phases  []*RecorderReport
+	Summary *SummaryReport
+}
+
+type SummaryReport struct {
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	ElapsedTime time.Duration
+	QueryCount  int
+	ErrorCount  int
+	AvgQPS      float64
+	Knee        string `json:",omitempty"`
+	Phases      []*PhaseSummary
+}
+
+type PhaseSummary struct {
+	Name        string
+	NAgents     int
+	Rate        int
+	LoadType    AutoGenerateSqlLoadType
+	ElapsedTime time.Duration
+	QueryCount  int
+	ErrorCount  int
+	AvgQPS      float64
+	P50         time.Duration
+	P95         time.Duration
+	P99         time.Duration
+	Knee        bool `json:",omitempty"`
+}
+
+// Phase with the same settings as the task
+func (task *Task) defaultPhase() *Phase {
+	return &Phase{
+		NAgents:                task.NAgents,
+		Time:                   task.Time,
+		NumberQueriesToExecute: task.NumberQueriesToExecute,
+		Rate:                   task.Rate,
+		RateStages:             task.RateStages,
+		WarmUp:                 task.WarmUp,
+		LoadType:               task.dataOpts.LoadType,
+	}
+}
+
+func (phase *Phase) apply(taskOpts *TaskOpts, dataOpts *DataOpts) (*TaskOpts, *DataOpts) {
+	newTaskOpts := *taskOpts
+	newTaskOpts.NAgents = phase.NAgents
+	newTaskOpts.Time = phase.Time
+	newTaskOpts.NumberQueriesToExecute = phase.NumberQueriesToExecute
+	newTaskOpts.Rate = phase.Rate
+	newTaskOpts.RateStages = phase.RateStages
+	newTaskOpts.WarmUp = phase.WarmUp
+
+	newDataOpts := *dataOpts
+	newDataOpts.LoadType = phase.LoadType
+
+	return &newTaskOpts, &newDataOpts
+}
+
+// Summarize the reports of all phases.
+// If kneeThreshold is greater than zero, the last phase before QPS increases by less than kneeThreshold% is marked as the knee.
+func Summarize(reports []*RecorderReport, kneeThreshold float64) *SummaryReport {
+	summary := &SummaryReport{
+		Phases: make([]*PhaseSummary, 0, len(reports)),
+	}
+
+	if len(reports) == 0 {
+		return summary
+	}
+
+	summary.StartedAt = reports[0].StartedAt
+	summary.FinishedAt = reports[len(reports)-1].FinishedAt
+	var nanoElapsed time.Duration
+
+	for _, rr := range reports {
+		summary.QueryCount += rr.QueryCount
+		summary.ErrorCount += rr.ErrorCount
+
+		if elapsed := rr.FinishedAt.Sub(rr.StartedAt.Add(rr.WarmUpTime * time.Second)); elapsed > 0 {
+			nanoElapsed += elapsed
+		}
+
+		summary.Phases = append(summary.Phases, &PhaseSummary{
+			Name:        rr.Phase,
+			NAgents:     rr.NAgents,
+			Rate:        rr.Rate,
+			LoadType:    rr.LoadType,
+			ElapsedTime: rr.ElapsedTime,
+			QueryCount:  rr.QueryCount,
+			ErrorCount:  rr.ErrorCount,
+			AvgQPS:      rr.AvgQPS,
+			P50:         rr.Response.Time.P50,
+			P95:         rr.Response.Time.P95,
+			P99:         rr.Response.Time.P99,
+		})
+	}
+
+	// NOTE: Warm-up time is excluded
+	summary.ElapsedTime = nanoElapsed / time.Second
+	summary.AvgQPS = perSecond(summary.QueryCount, nanoElapsed)
+
+	if kneeThreshold > 0 {
+		summary.markKnee(kneeThreshold)
+	}
+
+	return summary
+}
+
+func (summary *SummaryReport) markKnee(threshold float64) {
+	for i := 1; i < len(summary.Phases); i++ {
+		prev := summary.Phases[i-1]
+
+		if prev.AvgQPS <= 0 {
+			continue
+		}
+
+		gain := (summary.Phases[i].AvgQPS - prev.AvgQPS) / prev.AvgQPS * 100
+
+		if gain < threshold {
+			prev.Knee = true
+			summary.Knee = prev.Name
+			return
+		}
+	}
+}
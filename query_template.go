@@ -0,0 +1,287 @@
+package qlap
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/winebarrel/randstr"
+)
+
+const (
+	PlaceholderPrefix = "${"
+	PlaceholderSuffix = "}"
+	PlaceholderEscape = "$"
+	TimestampFormat   = "2006-01-02 15:04:05"
+)
+
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// Generates a value for a placeholder.
+// The value is int64 or string.
+type valueGenerator func(rnd *rand.Rand) interface{}
+
+type queryTemplate struct {
+	literals    []string // len(literals) == len(generators) + 1
+	generators  []valueGenerator
+	returnsRows bool
+}
+
+// Statements that return a result set
+var rowsReturningKeywords = []string{"SELECT", "SHOW", "WITH", "DESC", "DESCRIBE", "EXPLAIN", "VALUES", "TABLE", "CHECKSUM"}
+
+func parseQueryTemplates(queries []string) ([]*queryTemplate, error) {
+	tmpls := make([]*queryTemplate, len(queries))
+
+	for i, q := range queries {
+		tmpl, err := parseQueryTemplate(q)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse query (query=%s): %w", q, err)
+		}
+
+		tmpls[i] = tmpl
+	}
+
+	return tmpls, nil
+}
+
+// Placeholders:
+//
+//	${int:MIN:MAX}  random integer in [MIN, MAX]
+//	${str:N}        random string of length N
+//	${uuid}         UUID
+//	${seq}          sequence starting from 1 (or ${seq:START})
+//	${list:A,B,...} value picked from the list
+//	${now}          current timestamp (or ${now:DURATION} to shift randomly within +-DURATION)
+//
+// "$${" is a literal "${".
+func parseQueryTemplate(q string) (*queryTemplate, error) {
+	tmpl := &queryTemplate{returnsRows: returnsRows(q)}
+	rest := q
+	literal := ""
+
+	for {
+		start := strings.Index(rest, PlaceholderPrefix)
+
+		if start < 0 {
+			break
+		}
+
+		if strings.HasSuffix(rest[:start], PlaceholderEscape) {
+			literal += rest[:start-len(PlaceholderEscape)] + PlaceholderPrefix
+			rest = rest[start+len(PlaceholderPrefix):]
+			continue
+		}
+
+		end := strings.Index(rest[start:], PlaceholderSuffix)
+
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder: %s", rest[start:])
+		}
+
+		end += start
+		gen, err := newValueGenerator(rest[start+len(PlaceholderPrefix) : end])
+
+		if err != nil {
+			return nil, err
+		}
+
+		tmpl.literals = append(tmpl.literals, literal+rest[:start])
+		tmpl.generators = append(tmpl.generators, gen)
+		literal = ""
+		rest = rest[end+len(PlaceholderSuffix):]
+	}
+
+	tmpl.literals = append(tmpl.literals, literal+rest)
+
+	return tmpl, nil
+}
+
+func returnsRows(q string) bool {
+	q = strings.TrimLeft(q, " \t\r\n(")
+	fields := strings.Fields(q)
+
+	if len(fields) == 0 {
+		return false
+	}
+
+	keyword := strings.ToUpper(strings.TrimRight(fields[0], "("))
+
+	for _, kw := range rowsReturningKeywords {
+		if keyword == kw {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newValueGenerator(placeholder string) (valueGenerator, error) {
+	parts := strings.SplitN(placeholder, ":", 2)
+	name := strings.TrimSpace(parts[0])
+	args := []string{}
+
+	if len(parts) == 2 {
+		args = strings.Split(parts[1], ":")
+	}
+
+	switch name {
+	case "int":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("'int' requires MIN and MAX: ${%s}", placeholder)
+		}
+
+		min, err := strconv.ParseInt(strings.TrimSpace(args[0]), 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid MIN: ${%s}: %w", placeholder, err)
+		}
+
+		max, err := strconv.ParseInt(strings.TrimSpace(args[1]), 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid MAX: ${%s}: %w", placeholder, err)
+		}
+
+		if min > max {
+			return nil, fmt.Errorf("MIN must be <= MAX: ${%s}", placeholder)
+		}
+
+		// NOTE: The span overflows int64 if the range is wide, and is zero if it covers all of int64
+		span := uint64(max) - uint64(min) + 1
+
+		return func(rnd *rand.Rand) interface{} {
+			if span > 0 && span <= math.MaxInt64 {
+				return min + rnd.Int63n(int64(span))
+			}
+
+			for {
+				v := rnd.Uint64()
+
+				if span == 0 || v < span {
+					return int64(uint64(min) + v)
+				}
+			}
+		}, nil
+	case "str":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("'str' requires N: ${%s}", placeholder)
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(args[0]))
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid N: ${%s}: %w", placeholder, err)
+		}
+
+		if n < 1 {
+			return nil, fmt.Errorf("N must be >= 1: ${%s}", placeholder)
+		}
+
+		return func(rnd *rand.Rand) interface{} {
+			return randstr.String(rnd, n)
+		}, nil
+	case "uuid":
+		return func(_ *rand.Rand) interface{} {
+			return uuid.NewString()
+		}, nil
+	case "seq":
+		seq := int64(0)
+
+		if len(args) == 1 {
+			start, err := strconv.ParseInt(strings.TrimSpace(args[0]), 10, 64)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid START: ${%s}: %w", placeholder, err)
+			}
+
+			seq = start - 1
+		} else if len(args) > 1 {
+			return nil, fmt.Errorf("too many arguments: ${%s}", placeholder)
+		}
+
+		// NOTE: The sequence is shared by all agents
+		return func(_ *rand.Rand) interface{} {
+			return atomic.AddInt64(&seq, 1)
+		}, nil
+	case "list":
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("'list' requires values: ${%s}", placeholder)
+		}
+
+		values := strings.Split(parts[1], ",")
+
+		return func(rnd *rand.Rand) interface{} {
+			return values[rnd.Intn(len(values))]
+		}, nil
+	case "now":
+		var spread time.Duration
+
+		if len(args) == 1 {
+			d, err := time.ParseDuration(strings.TrimSpace(args[0]))
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid DURATION: ${%s}: %w", placeholder, err)
+			}
+
+			if d < 0 {
+				d = -d
+			}
+
+			spread = d
+		} else if len(args) > 1 {
+			return nil, fmt.Errorf("too many arguments: ${%s}", placeholder)
+		}
+
+		return func(rnd *rand.Rand) interface{} {
+			now := time.Now()
+
+			if spread > 0 {
+				now = now.Add(time.Duration(rnd.Int63n(int64(spread)*2+1)) - spread)
+			}
+
+			return now.Format(TimestampFormat)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown placeholder: ${%s}", placeholder)
+	}
+}
+
+// If "prepared" is true, placeholders are replaced with "?" and their values are returned as arguments
+func (tmpl *queryTemplate) render(rnd *rand.Rand, prepared bool) (string, []interface{}) {
+	if len(tmpl.generators) == 0 {
+		return tmpl.literals[0], nil
+	}
+
+	sb := &stmtBuilder{prepared: prepared}
+
+	for i, gen := range tmpl.generators {
+		sb.WriteString(tmpl.literals[i])
+		sb.writeValue(gen(rnd))
+	}
+
+	sb.WriteString(tmpl.literals[len(tmpl.literals)-1])
+
+	return sb.String(), sb.args
+}
+
+func sqlLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return "'" + escapeString(v) + "'"
+	default:
+		panic(fmt.Sprintf("Unsupported value type: %T", v))
+	}
+}
+
+func escapeString(str string) string {
+	return stringEscaper.Replace(str)
+}
@@ -0,0 +1,176 @@
+package qlap
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQueryTemplateError(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{"select ${int:1:10", "unclosed placeholder"},
+		{"select ${int:1}", "'int' requires MIN and MAX"},
+		{"select ${int:a:10}", "invalid MIN"},
+		{"select ${int:1:b}", "invalid MAX"},
+		{"select ${int:10:1}", "MIN must be <= MAX"},
+		{"select ${int:-9223372036854775809:0}", "invalid MIN"},
+		{"select ${str}", "'str' requires N"},
+		{"select ${str:x}", "invalid N"},
+		{"select ${str:0}", "N must be >= 1"},
+		{"select ${seq:x}", "invalid START"},
+		{"select ${seq:1:2}", "too many arguments"},
+		{"select ${list}", "'list' requires values"},
+		{"select ${list:}", "'list' requires values"},
+		{"select ${now:x}", "invalid DURATION"},
+		{"select ${now:1s:2s}", "too many arguments"},
+		{"select ${foo}", "unknown placeholder"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseQueryTemplate(tt.query)
+
+		if err == nil {
+			t.Errorf("%s: expected error", tt.query)
+		} else if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %q does not contain %q", tt.query, err, tt.err)
+		}
+	}
+}
+
+func TestQueryTemplateRender(t *testing.T) {
+	tests := []struct {
+		query    string
+		literal  string
+		prepared string
+		args     []interface{}
+	}{
+		{"select 1", "select 1", "select 1", nil},
+		{"select ${int:5:5}", "select 5", "select ?", []interface{}{int64(5)}},
+		{"select ${int:-3:-3}, ${list:a}", "select -3, 'a'", "select ?, ?", []interface{}{int64(-3), "a"}},
+		{"select ${list:it's}", `select 'it\'s'`, "select ?", []interface{}{"it's"}},
+		{"select '$${x}'", "select '${x}'", "select '${x}'", nil},
+		{"select '$${x}', ${int:1:1}, '$${y}'", "select '${x}', 1, '${y}'", "select '${x}', ?, '${y}'", []interface{}{int64(1)}},
+		{"select '$$${x}'", "select '$${x}'", "select '$${x}'", nil},
+		{"select '$x', '{x}', '$'", "select '$x', '{x}', '$'", "select '$x', '{x}', '$'", nil},
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, tt := range tests {
+		tmpl, err := parseQueryTemplate(tt.query)
+
+		if err != nil {
+			t.Errorf("%s: %s", tt.query, err)
+			continue
+		}
+
+		if got, args := tmpl.render(rnd, false); got != tt.literal || len(args) != 0 {
+			t.Errorf("%s: literal = %q %v, want %q", tt.query, got, args, tt.literal)
+		}
+
+		if got, args := tmpl.render(rnd, true); got != tt.prepared || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: prepared = %q %v, want %q %v", tt.query, got, args, tt.prepared, tt.args)
+		}
+	}
+}
+
+func TestQueryTemplateRenderValues(t *testing.T) {
+	tests := []struct {
+		query string
+		check func(v interface{}) bool
+	}{
+		{"${int:-10:10}", func(v interface{}) bool { n := v.(int64); return n >= -10 && n <= 10 }},
+		{"${int:-9223372036854775808:9223372036854775807}", func(v interface{}) bool { _, ok := v.(int64); return ok }},
+		{"${int:-1:9223372036854775807}", func(v interface{}) bool { return v.(int64) >= -1 }},
+		{"${int:9223372036854775807:9223372036854775807}", func(v interface{}) bool { return v.(int64) == math.MaxInt64 }},
+		{"${int:-9223372036854775808:-9223372036854775808}", func(v interface{}) bool { return v.(int64) == math.MinInt64 }},
+		{"${str:8}", func(v interface{}) bool { return len(v.(string)) == 8 }},
+		{"${uuid}", func(v interface{}) bool {
+			return regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`).MatchString(v.(string))
+		}},
+		{"${list:a,b,c}", func(v interface{}) bool { s := v.(string); return s == "a" || s == "b" || s == "c" }},
+		{"${now}", func(v interface{}) bool {
+			ts, err := time.ParseInLocation(TimestampFormat, v.(string), time.Local)
+			return err == nil && time.Since(ts) < time.Minute
+		}},
+		{"${now:1h}", func(v interface{}) bool {
+			ts, err := time.ParseInLocation(TimestampFormat, v.(string), time.Local)
+			d := time.Since(ts)
+			return err == nil && d > -time.Hour-time.Second && d < time.Hour+time.Minute
+		}},
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, tt := range tests {
+		tmpl, err := parseQueryTemplate(tt.query)
+
+		if err != nil {
+			t.Errorf("%s: %s", tt.query, err)
+			continue
+		}
+
+		for i := 0; i < 100; i++ {
+			_, args := tmpl.render(rnd, true)
+
+			if len(args) != 1 || !tt.check(args[0]) {
+				t.Errorf("%s: unexpected value %v", tt.query, args)
+				break
+			}
+		}
+	}
+}
+
+func TestQueryTemplateSeq(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"${seq}", []int64{1, 2, 3}},
+		{"${seq:1001}", []int64{1001, 1002, 1003}},
+		{"${seq:-1}", []int64{-1, 0, 1}},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := parseQueryTemplate(tt.query)
+
+		if err != nil {
+			t.Errorf("%s: %s", tt.query, err)
+			continue
+		}
+
+		for _, want := range tt.want {
+			if _, args := tmpl.render(nil, true); args[0] != want {
+				t.Errorf("%s: got %v, want %d", tt.query, args[0], want)
+			}
+		}
+	}
+}
+
+func TestReturnsRows(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT 1", true},
+		{"  (select 1) union (select 2)", true},
+		{"show tables", true},
+		{"with t as (select 1) select * from t", true},
+		{"insert into t values (1)", false},
+		{"update t set a = 1", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := returnsRows(tt.query); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -1,47 +1,138 @@
 package qlap
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"runtime"
 	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/winebarrel/tachymeter"
 )
 
 type RecorderReport struct {
+	Phase       string `json:",omitempty"`
 	DSN         string
 	StartedAt   time.Time
 	FinishedAt  time.Time
 	ElapsedTime time.Duration
+	WarmUpTime  time.Duration
 	TaskOpts
 	DataOpts
-	Token       string
-	GOMAXPROCS  int
-	QueryCount  int
-	AvgQPS      float64
-	MaxQPS      float64
-	MinQPS      float64
-	MedianQPS   float64
-	ExpectedQPS int
-	Response    *tachymeter.Metrics
+	Token             string
+	GOMAXPROCS        int
+	QueryCount        int
+	AvgQPS            float64
+	MaxQPS            float64
+	MinQPS            float64
+	MedianQPS         float64
+	ExpectedQPS       int
+	RowsReturned      int
+	BytesRead         int
+	Response          *tachymeter.Metrics
+	CorrectedResponse *tachymeter.Metrics // Response time from the intended start time in open-loop mode
+	Percentiles       map[string]string   `json:",omitempty"`
+	ErrorCount        int
+	Errors            []*RecorderErrorReport
+	Statements        map[string]*RecorderStatementReport
+	Transaction       *RecorderTransactionReport
+	Connection        *RecorderConnectionReport `json:",omitempty"`
+	Outages           []*RecorderOutageReport   `json:",omitempty"`
+	Agents            []*RecorderAgentReport    `json:",omitempty"`
+}
+
+type RecorderTransactionReport struct {
+	TransactionCount int
+	AvgTPS           float64
+	ErrorCount       int
+	RollbackCount    int
+	RetryCount       int
+	Response         *tachymeter.Metrics
+}
+
+type RecorderConnectionReport struct {
+	ConnectCount int
+	AvgCPS       float64 // Connections per second
+	ErrorCount   int
+	Response     *tachymeter.Metrics // Connect time including the initial queries
+}
+
+// Period from the first lost connection until queries succeed again
+type RecorderOutageReport struct {
+	FirstErrorAt  time.Time
+	RecoveredAt   time.Time     // When queries succeeded again on all affected agents
+	Recovered     bool          // False if the test finished during the outage
+	Downtime      time.Duration // Until queries succeeded again on any affected agent
+	TimeToRecover time.Duration // Until queries succeeded again on all affected agents
+	Agents        int
+	FailedQueries int
+}
+
+type RecorderStatementReport struct {
+	QueryCount   int
+	AvgQPS       float64
+	ErrorCount   int
+	RowsReturned int
+	BytesRead    int
+	Response     *tachymeter.Metrics
+}
+
+type RecorderAgentReport struct {
+	Id               int
+	QueryCount       int
+	AvgQPS           float64
+	ErrorCount       int
+	Reconnects       int // Reconnects on lost connections
+	ForcedReconnects int // Reconnects by the connection mode
+	P50              time.Duration
+	P95              time.Duration
+	P99              time.Duration
+	Deviation        float64 // Difference of QPS from the mean of all agents (%)
+	Outlier          bool    `json:",omitempty"`
+}
+
+type RecorderErrorReport struct {
+	Number  uint16
+	Count   int
+	Message string
 }
 
 type RecorderOpts struct {
-	DSN       string
-	HInterval time.Duration
+	DSN                string
+	HInterval          time.Duration
+	HistogramPrecision int       // Number of significant decimal digits of latencies
+	Percentiles        []float64 // Additional percentiles of the response time
+	AgentStats         bool
+	AgentDeviation     float64 // Threshold of the QPS deviation to highlight agents (%)
+	TimeSeries         string
+	TimeSeriesFormat   TimeSeriesFormat
+	MetricsAddr        string
+	Samples            string
 }
 
 type Recorder struct {
+	errCnt int64 // NOTE: Keep 64-bit alignment for atomic operations
 	sync.Mutex
 	RecorderOpts
 	TaskOpts
 	DataOpts
+	phase      string
 	startedAt  time.Time
 	finishedAt time.Time
 	token      string
 	channel    chan []recorderDataPoint
-	dataPoints []recorderDataPoint
+	done       chan struct{}
+	agentStats map[int]*agentStats
+	qpsCounts  []int // Number of queries per second after warm-up
+	execCnt    int
+	timeSeries *timeSeries
+	metrics    *Metrics
+	samples    *samplesWriter
 }
 
 func newRecorder(recOpts *RecorderOpts, taskOpts *TaskOpts, dataOpts *DataOpts, token string) (rec *Recorder) {
@@ -52,60 +143,353 @@ func newRecorder(recOpts *RecorderOpts, taskOpts *TaskOpts, dataOpts *DataOpts,
 		token:        token,
 	}
 
+	if rec.HistogramPrecision < 1 {
+		rec.HistogramPrecision = DefaultHistogramPrecision
+	}
+
 	return
 }
 
+// Statistics of each agent are merged at report time
+type agentStats struct {
+	statements map[string]*statementStats
+	errors     map[uint16]*RecorderErrorReport
+	tx         *transactionStats
+	conn       *connectionStats
+	reconnects int
+	forced     int // Reconnects by the connection mode
+	outages    []*outage
+	outage     *outage // Current outage
+}
+
+type outage struct {
+	agentId       int
+	firstErrorAt  time.Time
+	recoveredAt   time.Time
+	failedQueries int
+	lostWorkers   map[int]bool // Workers that lost the connection and have not succeeded yet
+}
+
+type statementStats struct {
+	queryCnt  int
+	errCnt    int
+	rowCnt    int
+	byteCnt   int
+	hist      *histogram
+	corrected *histogram // Response time from the intended start time in open-loop mode
+}
+
+type transactionStats struct {
+	txCnt       int
+	errCnt      int
+	rollbackCnt int
+	retryCnt    int
+	hist        *histogram
+}
+
+type connectionStats struct {
+	connectCnt int
+	errCnt     int
+	hist       *histogram
+}
+
 func (rec *Recorder) start(bufsize int) {
-	rec.dataPoints = []recorderDataPoint{}
+	rec.agentStats = map[int]*agentStats{}
 	ch := make(chan []recorderDataPoint, bufsize)
 	rec.channel = ch
+	rec.done = make(chan struct{})
+	rec.startedAt = time.Now()
+
+	if rec.samples != nil {
+		rec.samples.startPhase(rec)
+	}
+
+	if rec.timeSeries != nil {
+		go rec.writeTimeSeries()
+	}
 
 	go func() {
 		for redDps := range ch {
 			rec.appendDataPoints(redDps)
 		}
+
+		close(rec.done)
 	}()
+}
 
-	rec.startedAt = time.Now()
+func (rec *Recorder) writeTimeSeries() {
+	tick := time.NewTicker(TimeSeriesPeriod)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-rec.done:
+			return
+		case <-tick.C:
+			err := rec.timeSeries.flush(time.Now().Add(-TimeSeriesLag))
+
+			if err != nil {
+				return
+			}
+		}
+	}
 }
 
 func (rec *Recorder) appendDataPoints(recDps []recorderDataPoint) {
+	// NOTE: Workers of the agent send data points in the order of completion, not in the order of timestamps
+	if rec.OpenLoop {
+		sort.SliceStable(recDps, func(i, j int) bool {
+			return recDps[i].timestamp.Before(recDps[j].timestamp)
+		})
+	}
+
+	if rec.timeSeries != nil {
+		rec.timeSeries.add(recDps)
+	}
+
+	if rec.metrics != nil {
+		rec.metrics.add(recDps)
+	}
+
+	if rec.samples != nil {
+		rec.samples.add(recDps)
+	}
+
 	rec.Lock()
 	defer rec.Unlock()
-	rec.dataPoints = append(rec.dataPoints, recDps...)
+	rec.execCnt += len(recDps)
+
+	// Leave out the data points during warm-up
+	warmUpEnd := rec.startedAt.Add(rec.WarmUp)
+
+	for _, v := range recDps {
+		if v.timestamp.Before(warmUpEnd) {
+			continue
+		}
+
+		rec.statsOf(v.agentId).add(&v, rec.HistogramPrecision, rec.OpenLoop)
+
+		if v.err == nil {
+			sec := int(v.timestamp.Sub(warmUpEnd) / time.Second)
+
+			for len(rec.qpsCounts) <= sec {
+				rec.qpsCounts = append(rec.qpsCounts, 0)
+			}
+
+			rec.qpsCounts[sec]++
+		}
+	}
 }
 
-func (rec *Recorder) close() {
-	close(rec.channel)
-	rec.finishedAt = time.Now()
+func (rec *Recorder) addTransactions(txDps []recorderTxDataPoint) {
+	if len(txDps) == 0 {
+		return
+	}
+
+	if rec.samples != nil {
+		rec.samples.addTransactions(txDps)
+	}
+
+	rec.Lock()
+	defer rec.Unlock()
+	warmUpEnd := rec.startedAt.Add(rec.WarmUp)
+
+	for _, v := range txDps {
+		if !v.timestamp.Before(warmUpEnd) {
+			rec.statsOf(v.agentId).addTransaction(&v, rec.HistogramPrecision)
+		}
+	}
 }
 
-func (rec *Recorder) qpsHist() []float64 {
-	recDps := rec.dataPoints
+func (rec *Recorder) addConnects(connDps []recorderConnectDataPoint) {
+	if len(connDps) == 0 {
+		return
+	}
 
-	if len(recDps) == 0 {
-		return []float64{}
+	if rec.samples != nil {
+		rec.samples.addConnects(connDps)
 	}
 
-	sort.Slice(recDps, func(i, j int) bool {
-		return recDps[i].timestamp.Before(recDps[j].timestamp)
-	})
+	rec.Lock()
+	defer rec.Unlock()
+	warmUpEnd := rec.startedAt.Add(rec.WarmUp)
 
-	minTm := recDps[0].timestamp
-	hist := []int{0}
+	for _, v := range connDps {
+		if !v.timestamp.Before(warmUpEnd) {
+			rec.statsOf(v.agentId).addConnect(&v, rec.HistogramPrecision)
+		}
+	}
+}
 
-	for _, v := range recDps {
-		if minTm.Add(1 * time.Second).Before(v.timestamp) {
-			minTm = minTm.Add(1 * time.Second)
-			hist = append(hist, 0)
+func (rec *Recorder) addReconnects(agentId int, n int, forced int) {
+	if rec.samples != nil {
+		rec.samples.addReconnects(agentId, n, forced)
+	}
+
+	rec.Lock()
+	defer rec.Unlock()
+	as := rec.statsOf(agentId)
+	as.reconnects += n
+	as.forced += forced
+}
+
+func (rec *Recorder) statsOf(agentId int) *agentStats {
+	as, ok := rec.agentStats[agentId]
+
+	if !ok {
+		as = &agentStats{
+			statements: map[string]*statementStats{},
+			errors:     map[uint16]*RecorderErrorReport{},
+		}
+
+		rec.agentStats[agentId] = as
+	}
+
+	return as
+}
+
+func (as *agentStats) add(v *recorderDataPoint, precision int, openLoop bool) {
+	as.trackOutage(v)
+	ss, ok := as.statements[v.tag]
+
+	if !ok {
+		ss = &statementStats{hist: newHistogram(precision)}
+
+		if openLoop {
+			ss.corrected = newHistogram(precision)
+		}
+
+		as.statements[v.tag] = ss
+	}
+
+	if v.err != nil {
+		ss.errCnt++
+		as.addError(v.err)
+		return
+	}
+
+	ss.queryCnt++
+	ss.rowCnt += v.rowCnt
+	ss.byteCnt += v.byteCnt
+	ss.hist.record(v.resTime)
+
+	if ss.corrected != nil {
+		ss.corrected.record(v.schedDelay + v.resTime)
+	}
+}
+
+// The outage ends when all workers that lost the connection succeed again,
+// because other workers of the agent may succeed during the outage in open-loop mode
+func (as *agentStats) trackOutage(v *recorderDataPoint) {
+	if v.connLost {
+		if as.outage == nil {
+			as.outage = &outage{agentId: v.agentId, firstErrorAt: v.timestamp, lostWorkers: map[int]bool{}}
+		}
+
+		as.outage.failedQueries++
+		as.outage.lostWorkers[v.workerId] = true
+
+		return
+	}
+
+	if as.outage == nil {
+		return
+	}
+
+	if v.err != nil {
+		as.outage.failedQueries++
+		return
+	}
+
+	delete(as.outage.lostWorkers, v.workerId)
+
+	if len(as.outage.lostWorkers) > 0 {
+		return
+	}
+
+	as.outage.recoveredAt = v.timestamp
+	as.outages = append(as.outages, as.outage)
+	as.outage = nil
+}
+
+func (as *agentStats) addTransaction(v *recorderTxDataPoint, precision int) {
+	if as.tx == nil {
+		as.tx = &transactionStats{hist: newHistogram(precision)}
+	}
+
+	as.tx.rollbackCnt += v.rollbackCnt
+	as.tx.retryCnt += v.retryCnt
+
+	if v.err != nil {
+		as.tx.errCnt++
+		return
+	}
+
+	as.tx.txCnt++
+	as.tx.hist.record(v.resTime)
+}
+
+func (as *agentStats) addConnect(v *recorderConnectDataPoint, precision int) {
+	if as.conn == nil {
+		as.conn = &connectionStats{hist: newHistogram(precision)}
+	}
+
+	if v.err != nil {
+		as.conn.errCnt++
+		as.addError(v.err)
+		return
+	}
+
+	as.conn.connectCnt++
+	as.conn.hist.record(v.resTime)
+}
+
+func (as *agentStats) addError(err error) {
+	num, msg := errorNumberAndMessage(err)
+
+	if er, ok := as.errors[num]; ok {
+		er.Count++
+	} else {
+		as.errors[num] = &RecorderErrorReport{Number: num, Count: 1, Message: msg}
+	}
+}
+
+func (rec *Recorder) close() error {
+	close(rec.channel)
+	<-rec.done
+	rec.finishedAt = time.Now()
+
+	// NOTE: The time series file is shared by all phases and closed by the task
+	if rec.timeSeries != nil {
+		err := rec.timeSeries.flush(rec.finishedAt.Add(TimeSeriesPeriod))
+
+		if err != nil {
+			return fmt.Errorf("Failed to write time series: %w", err)
 		}
+	}
 
-		hist[len(hist)-1]++
+	if rec.samples != nil {
+		err := rec.samples.finishPhase(rec.finishedAt)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write samples: %w", err)
+		}
 	}
 
-	f64Hist := make([]float64, len(hist))
+	return nil
+}
 
-	for i, v := range hist {
+func (rec *Recorder) qpsHist() []float64 {
+	counts := rec.qpsCounts
+
+	// Leave out the last partial second
+	if full := int(rec.finishedAt.Sub(rec.startedAt.Add(rec.WarmUp)) / time.Second); full > 0 && len(counts) > full {
+		counts = counts[:full]
+	}
+
+	f64Hist := make([]float64, len(counts))
+
+	for i, v := range counts {
 		f64Hist[i] = float64(v)
 	}
 
@@ -143,50 +527,362 @@ func (rec *Recorder) qps() (minQPS float64, maxQPS float64, medianQPS float64) {
 }
 
 type recorderDataPoint struct {
+	timestamp  time.Time
+	agentId    int
+	workerId   int // Worker connection in open-loop mode
+	tag        string
+	resTime    time.Duration
+	schedDelay time.Duration // Delay from the intended start time in open-loop mode
+	rowCnt     int
+	byteCnt    int
+	err        error
+	connLost   bool // The error is caused by the lost connection
+}
+
+type recorderTxDataPoint struct {
+	timestamp   time.Time
+	agentId     int
+	resTime     time.Duration
+	rollbackCnt int
+	retryCnt    int
+	err         error
+}
+
+type recorderConnectDataPoint struct {
 	timestamp time.Time
+	agentId   int
 	resTime   time.Duration
+	err       error
 }
 
 func (rec *Recorder) add(recDps []recorderDataPoint) {
 	rec.channel <- recDps
 }
 
+func (rec *Recorder) inWarmUp() bool {
+	return time.Now().Before(rec.startedAt.Add(rec.WarmUp))
+}
+
+func (rec *Recorder) countErrors(n int) int {
+	return int(atomic.AddInt64(&rec.errCnt, int64(n)))
+}
+
 func (rec *Recorder) Report() (rr *RecorderReport) {
-	nanoElapsed := rec.finishedAt.Sub(rec.startedAt)
-	queryCnt := rec.Count()
+	nanoElapsed := rec.finishedAt.Sub(rec.startedAt.Add(rec.WarmUp))
+
+	if nanoElapsed < 0 {
+		nanoElapsed = 0
+	}
 
 	rr = &RecorderReport{
+		Phase:       rec.phase,
 		DSN:         rec.DSN,
 		StartedAt:   rec.startedAt,
 		FinishedAt:  rec.finishedAt,
 		ElapsedTime: nanoElapsed / time.Second,
+		WarmUpTime:  rec.WarmUp / time.Second,
 		TaskOpts:    rec.TaskOpts,
 		DataOpts:    rec.DataOpts,
 		Token:       rec.token,
 		GOMAXPROCS:  runtime.GOMAXPROCS(0),
-		QueryCount:  queryCnt,
-		AvgQPS:      float64(queryCnt) * float64(time.Second) / float64(nanoElapsed),
-		ExpectedQPS: rec.NAgents * rec.Rate,
+		ExpectedQPS: rec.NAgents * rateAt(rec.Rate, rec.RateStages, rec.finishedAt.Sub(rec.startedAt)),
 	}
 
-	t := tachymeter.New(&tachymeter.Config{
-		Size:      len(rec.dataPoints),
-		HBins:     10,
-		HInterval: rec.HInterval,
-	})
+	total := newHistogram(rec.HistogramPrecision)
+	var corrected *histogram
+
+	if rec.OpenLoop {
+		corrected = newHistogram(rec.HistogramPrecision)
+	}
+
+	errReports := map[uint16]*RecorderErrorReport{}
+	stmtReports := map[string]*RecorderStatementReport{}
+	stmtHists := map[string]*histogram{}
+
+	for _, as := range rec.agentStats {
+		for tag, ss := range as.statements {
+			sr, ok := stmtReports[tag]
+
+			if !ok {
+				sr = &RecorderStatementReport{}
+				stmtReports[tag] = sr
+				stmtHists[tag] = newHistogram(rec.HistogramPrecision)
+			}
+
+			sr.QueryCount += ss.queryCnt
+			sr.ErrorCount += ss.errCnt
+			sr.RowsReturned += ss.rowCnt
+			sr.BytesRead += ss.byteCnt
+			stmtHists[tag].merge(ss.hist)
+			total.merge(ss.hist)
+
+			if corrected != nil {
+				corrected.merge(ss.corrected)
+			}
+
+			rr.QueryCount += ss.queryCnt
+			rr.ErrorCount += ss.errCnt
+			rr.RowsReturned += ss.rowCnt
+			rr.BytesRead += ss.byteCnt
+		}
 
-	for _, v := range rec.dataPoints {
-		t.AddTime(v.resTime)
+		for num, er := range as.errors {
+			if ter, ok := errReports[num]; ok {
+				ter.Count += er.Count
+			} else {
+				errReports[num] = &RecorderErrorReport{Number: num, Count: er.Count, Message: er.Message}
+			}
+		}
+	}
+
+	rr.AvgQPS = perSecond(rr.QueryCount, nanoElapsed)
+	rr.Response = total.metrics(rec.HInterval)
+
+	if corrected != nil {
+		rr.CorrectedResponse = corrected.metrics(rec.HInterval)
+	}
+
+	if len(rec.Percentiles) > 0 && total.count > 0 {
+		rr.Percentiles = map[string]string{}
+
+		for _, p := range rec.Percentiles {
+			rr.Percentiles[PercentileName(p)] = total.percentile(p / 100).String()
+		}
+	}
+
+	for tag, sr := range stmtReports {
+		sr.AvgQPS = perSecond(sr.QueryCount, nanoElapsed)
+		sr.Response = stmtHists[tag].metrics(rec.HInterval)
+	}
+
+	rr.Statements = stmtReports
+
+	if rec.Transaction {
+		rr.Transaction = rec.transactionReport(nanoElapsed)
+	}
+
+	if rec.ConnectionMode == ConnectionModePerQuery || rec.ConnectionMode == ConnectionModePerTransaction || rec.AutoReconnect {
+		rr.Connection = rec.connectionReport(nanoElapsed)
+	}
+
+	rr.Outages = rec.outageReports()
+
+	if rec.AgentStats {
+		rr.Agents = rec.agentReports(nanoElapsed)
 	}
 
-	rr.Response = t.Calc()
 	rr.MinQPS, rr.MaxQPS, rr.MedianQPS = rec.qps()
+	rr.Errors = make([]*RecorderErrorReport, 0, len(errReports))
+
+	for _, er := range errReports {
+		rr.Errors = append(rr.Errors, er)
+	}
+
+	sort.Slice(rr.Errors, func(i, j int) bool {
+		return rr.Errors[i].Number < rr.Errors[j].Number
+	})
 
 	return
 }
 
+func (rec *Recorder) transactionReport(nanoElapsed time.Duration) *RecorderTransactionReport {
+	tr := &RecorderTransactionReport{}
+	hist := newHistogram(rec.HistogramPrecision)
+
+	for _, as := range rec.agentStats {
+		if as.tx == nil {
+			continue
+		}
+
+		tr.TransactionCount += as.tx.txCnt
+		tr.ErrorCount += as.tx.errCnt
+		tr.RollbackCount += as.tx.rollbackCnt
+		tr.RetryCount += as.tx.retryCnt
+		hist.merge(as.tx.hist)
+	}
+
+	tr.AvgTPS = perSecond(tr.TransactionCount, nanoElapsed)
+	tr.Response = hist.metrics(rec.HInterval)
+
+	return tr
+}
+
+func (rec *Recorder) connectionReport(nanoElapsed time.Duration) *RecorderConnectionReport {
+	cr := &RecorderConnectionReport{}
+	hist := newHistogram(rec.HistogramPrecision)
+
+	for _, as := range rec.agentStats {
+		if as.conn == nil {
+			continue
+		}
+
+		cr.ConnectCount += as.conn.connectCnt
+		cr.ErrorCount += as.conn.errCnt
+		hist.merge(as.conn.hist)
+	}
+
+	cr.AvgCPS = perSecond(cr.ConnectCount, nanoElapsed)
+	cr.Response = hist.metrics(rec.HInterval)
+
+	return cr
+}
+
+// Merge overlapping outages of agents
+func (rec *Recorder) outageReports() []*RecorderOutageReport {
+	outages := []*outage{}
+
+	for _, as := range rec.agentStats {
+		outages = append(outages, as.outages...)
+
+		if as.outage != nil {
+			outages = append(outages, as.outage)
+		}
+	}
+
+	if len(outages) == 0 {
+		return nil
+	}
+
+	sort.Slice(outages, func(i, j int) bool {
+		return outages[i].firstErrorAt.Before(outages[j].firstErrorAt)
+	})
+
+	reports := []*RecorderOutageReport{}
+	var or *RecorderOutageReport
+	var firstRecoveredAt time.Time
+
+	closeReport := func() {
+		if or.Recovered {
+			or.TimeToRecover = or.RecoveredAt.Sub(or.FirstErrorAt)
+			or.Downtime = firstRecoveredAt.Sub(or.FirstErrorAt)
+		} else {
+			or.RecoveredAt = time.Time{}
+			or.TimeToRecover = rec.finishedAt.Sub(or.FirstErrorAt)
+			or.Downtime = or.TimeToRecover
+
+			if !firstRecoveredAt.IsZero() {
+				or.Downtime = firstRecoveredAt.Sub(or.FirstErrorAt)
+			}
+		}
+
+		reports = append(reports, or)
+	}
+
+	for _, o := range outages {
+		if or != nil && (!or.Recovered || !o.firstErrorAt.After(or.RecoveredAt)) {
+			or.Agents++
+			or.FailedQueries += o.failedQueries
+
+			if o.recoveredAt.IsZero() {
+				or.Recovered = false
+			} else {
+				if o.recoveredAt.After(or.RecoveredAt) {
+					or.RecoveredAt = o.recoveredAt
+				}
+
+				if firstRecoveredAt.IsZero() || o.recoveredAt.Before(firstRecoveredAt) {
+					firstRecoveredAt = o.recoveredAt
+				}
+			}
+
+			continue
+		}
+
+		if or != nil {
+			closeReport()
+		}
+
+		or = &RecorderOutageReport{
+			FirstErrorAt:  o.firstErrorAt,
+			RecoveredAt:   o.recoveredAt,
+			Recovered:     !o.recoveredAt.IsZero(),
+			Agents:        1,
+			FailedQueries: o.failedQueries,
+		}
+
+		firstRecoveredAt = o.recoveredAt
+	}
+
+	closeReport()
+
+	return reports
+}
+
+func (rec *Recorder) agentReports(nanoElapsed time.Duration) []*RecorderAgentReport {
+	reports := make([]*RecorderAgentReport, 0, len(rec.agentStats))
+	var sumQPS float64
+
+	for id, as := range rec.agentStats {
+		ar := &RecorderAgentReport{Id: id, Reconnects: as.reconnects, ForcedReconnects: as.forced}
+		hist := newHistogram(rec.HistogramPrecision)
+
+		for _, ss := range as.statements {
+			ar.QueryCount += ss.queryCnt
+			ar.ErrorCount += ss.errCnt
+			hist.merge(ss.hist)
+		}
+
+		ar.AvgQPS = perSecond(ar.QueryCount, nanoElapsed)
+
+		if hist.count > 0 {
+			ar.P50 = hist.valueAtRank(hist.count/2 + 1)
+			ar.P95 = hist.percentile(0.95)
+			ar.P99 = hist.percentile(0.99)
+		}
+
+		sumQPS += ar.AvgQPS
+		reports = append(reports, ar)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Id < reports[j].Id
+	})
+
+	if len(reports) == 0 || sumQPS == 0 {
+		return reports
+	}
+
+	meanQPS := sumQPS / float64(len(reports))
+
+	for _, ar := range reports {
+		ar.Deviation = (ar.AvgQPS - meanQPS) / meanQPS * 100
+		ar.Outlier = math.Abs(ar.Deviation) > rec.AgentDeviation
+	}
+
+	return reports
+}
+
+// Number of executed queries including warm-up
 func (rec *Recorder) Count() int {
 	rec.Lock()
 	defer rec.Unlock()
-	return len(rec.dataPoints)
+	return rec.execCnt
+}
+
+func (rec *Recorder) ErrorCount() int {
+	return int(atomic.LoadInt64(&rec.errCnt))
+}
+
+func perSecond(cnt int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(cnt) * float64(time.Second) / float64(elapsed)
+}
+
+// e.g. 99.9 -> "P99.9"
+func PercentileName(p float64) string {
+	return "P" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// NOTE: Errors other than MySQLError are counted as number 0
+func errorNumberAndMessage(err error) (uint16, string) {
+	var myErr *mysql.MySQLError
+
+	if errors.As(err, &myErr) {
+		return myErr.Number, myErr.Message
+	}
+
+	return 0, err.Error()
 }
@@ -0,0 +1,168 @@
+package qlap
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/winebarrel/tachymeter"
+)
+
+// tachymeter.Metrics is marshaled with durations as strings, e.g. "1.5ms",
+// so unmarshal it through this struct to load saved reports
+type metricsJSON struct {
+	Time struct {
+		Cumulative string
+		HMean      string
+		Avg        string
+		P50        string
+		P75        string
+		P95        string
+		P99        string
+		P999       string
+		Long5p     string
+		Short5p    string
+		Max        string
+		Min        string
+		Range      string
+		StdDev     string
+	}
+	Rate struct {
+		Second float64
+	}
+	Samples   int
+	Count     int
+	Histogram *tachymeter.Histogram
+}
+
+func (mj *metricsJSON) metrics() (*tachymeter.Metrics, error) {
+	if mj == nil {
+		return nil, nil
+	}
+
+	m := &tachymeter.Metrics{
+		Histogram: mj.Histogram,
+		Samples:   mj.Samples,
+		Count:     mj.Count,
+	}
+
+	m.Rate.Second = mj.Rate.Second
+
+	fields := []struct {
+		str string
+		d   *time.Duration
+	}{
+		{mj.Time.Cumulative, &m.Time.Cumulative},
+		{mj.Time.HMean, &m.Time.HMean},
+		{mj.Time.Avg, &m.Time.Avg},
+		{mj.Time.P50, &m.Time.P50},
+		{mj.Time.P75, &m.Time.P75},
+		{mj.Time.P95, &m.Time.P95},
+		{mj.Time.P99, &m.Time.P99},
+		{mj.Time.P999, &m.Time.P999},
+		{mj.Time.Long5p, &m.Time.Long5p},
+		{mj.Time.Short5p, &m.Time.Short5p},
+		{mj.Time.Max, &m.Time.Max},
+		{mj.Time.Min, &m.Time.Min},
+		{mj.Time.Range, &m.Time.Range},
+		{mj.Time.StdDev, &m.Time.StdDev},
+	}
+
+	for _, f := range fields {
+		if f.str == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(f.str)
+
+		if err != nil {
+			return nil, err
+		}
+
+		*f.d = d
+	}
+
+	return m, nil
+}
+
+func (rr *RecorderReport) UnmarshalJSON(data []byte) error {
+	type report RecorderReport
+
+	aux := &struct {
+		*report
+		Response          *metricsJSON
+		CorrectedResponse *metricsJSON
+	}{report: (*report)(rr)}
+
+	err := json.Unmarshal(data, aux)
+
+	if err != nil {
+		return err
+	}
+
+	rr.Response, err = aux.Response.metrics()
+
+	if err != nil {
+		return err
+	}
+
+	rr.CorrectedResponse, err = aux.CorrectedResponse.metrics()
+
+	return err
+}
+
+func (sr *RecorderStatementReport) UnmarshalJSON(data []byte) error {
+	type report RecorderStatementReport
+
+	aux := &struct {
+		*report
+		Response *metricsJSON
+	}{report: (*report)(sr)}
+
+	err := json.Unmarshal(data, aux)
+
+	if err != nil {
+		return err
+	}
+
+	sr.Response, err = aux.Response.metrics()
+
+	return err
+}
+
+func (tr *RecorderTransactionReport) UnmarshalJSON(data []byte) error {
+	type report RecorderTransactionReport
+
+	aux := &struct {
+		*report
+		Response *metricsJSON
+	}{report: (*report)(tr)}
+
+	err := json.Unmarshal(data, aux)
+
+	if err != nil {
+		return err
+	}
+
+	tr.Response, err = aux.Response.metrics()
+
+	return err
+}
+
+func (cr *RecorderConnectionReport) UnmarshalJSON(data []byte) error {
+	type report RecorderConnectionReport
+
+	aux := &struct {
+		*report
+		Response *metricsJSON
+	}{report: (*report)(cr)}
+
+	err := json.Unmarshal(data, aux)
+
+	if err != nil {
+		return err
+	}
+
+	cr.Response, err = aux.Response.metrics()
+
+	return err
+}
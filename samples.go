@@ -0,0 +1,447 @@
+package qlap
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	SamplesKindQuery       = "q"
+	SamplesKindTransaction = "tx"
+	SamplesKindConnect     = "c"
+	SamplesKindReconnects  = "r"
+	SamplesKindPhase       = "phase"
+	SamplesKindFinished    = "finished"
+)
+
+var samplesHeader = []string{
+	"kind", "timestamp_ns", "agent", "tag", "latency_ns", "sched_delay_ns",
+	"rows", "bytes", "rollbacks", "retries", "error_number", "error_message", "connection_lost", "worker",
+}
+
+// Settings of the phase written before its samples
+type samplesPhase struct {
+	Phase     string
+	DSN       string
+	StartedAt time.Time
+	WarmUp    time.Duration
+	Token     string
+	TaskOpts  TaskOpts
+	DataOpts  DataOpts
+}
+
+type samplesFinished struct {
+	FinishedAt time.Time
+}
+
+// Raw samples file (CSV) to rebuild reports offline.
+// Each phase starts with a "phase,{...}" record and ends with a "finished,{...}" record.
+type samplesWriter struct {
+	sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	csv    *csv.Writer
+	err    error
+}
+
+func newSamplesWriter(path string) (*samplesWriter, error) {
+	file, err := os.Create(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	writer := bufio.NewWriter(file)
+	sw := &samplesWriter{
+		file:   file,
+		writer: writer,
+		csv:    csv.NewWriter(writer),
+	}
+
+	sw.csv.Write(samplesHeader) //nolint:errcheck
+
+	return sw, nil
+}
+
+// Write a record of the kind and the JSON value
+func (sw *samplesWriter) writeJSON(kind string, v interface{}) {
+	sw.Lock()
+	defer sw.Unlock()
+
+	if sw.err != nil {
+		return
+	}
+
+	rawJson, err := json.Marshal(v)
+
+	if err != nil {
+		sw.err = err
+		return
+	}
+
+	sw.err = sw.csv.Write([]string{kind, string(rawJson)})
+}
+
+func (sw *samplesWriter) startPhase(rec *Recorder) {
+	sw.writeJSON(SamplesKindPhase, &samplesPhase{
+		Phase:     rec.phase,
+		DSN:       rec.DSN,
+		StartedAt: rec.startedAt,
+		WarmUp:    rec.WarmUp,
+		Token:     rec.token,
+		TaskOpts:  rec.TaskOpts,
+		DataOpts:  rec.DataOpts,
+	})
+}
+
+func (sw *samplesWriter) finishPhase(finishedAt time.Time) error {
+	sw.writeJSON(SamplesKindFinished, &samplesFinished{FinishedAt: finishedAt})
+
+	sw.Lock()
+	defer sw.Unlock()
+
+	if sw.err == nil {
+		sw.csv.Flush()
+		sw.err = sw.writer.Flush()
+	}
+
+	return sw.err
+}
+
+func (sw *samplesWriter) add(recDps []recorderDataPoint) {
+	sw.Lock()
+	defer sw.Unlock()
+
+	for _, v := range recDps {
+		if sw.err != nil {
+			return
+		}
+
+		num, msg := samplesError(v.err)
+
+		sw.err = sw.csv.Write([]string{
+			SamplesKindQuery,
+			strconv.FormatInt(v.timestamp.UnixNano(), 10),
+			strconv.Itoa(v.agentId),
+			v.tag,
+			strconv.FormatInt(int64(v.resTime), 10),
+			strconv.FormatInt(int64(v.schedDelay), 10),
+			strconv.Itoa(v.rowCnt),
+			strconv.Itoa(v.byteCnt),
+			"0",
+			"0",
+			num,
+			msg,
+			strconv.FormatBool(v.connLost),
+			strconv.Itoa(v.workerId),
+		})
+	}
+}
+
+func (sw *samplesWriter) addTransactions(txDps []recorderTxDataPoint) {
+	sw.Lock()
+	defer sw.Unlock()
+
+	for _, v := range txDps {
+		if sw.err != nil {
+			return
+		}
+
+		num, msg := samplesError(v.err)
+
+		sw.err = sw.csv.Write([]string{
+			SamplesKindTransaction,
+			strconv.FormatInt(v.timestamp.UnixNano(), 10),
+			strconv.Itoa(v.agentId),
+			"",
+			strconv.FormatInt(int64(v.resTime), 10),
+			"0",
+			"0",
+			"0",
+			strconv.Itoa(v.rollbackCnt),
+			strconv.Itoa(v.retryCnt),
+			num,
+			msg,
+			"false",
+			"0",
+		})
+	}
+}
+
+func (sw *samplesWriter) addConnects(connDps []recorderConnectDataPoint) {
+	sw.Lock()
+	defer sw.Unlock()
+
+	for _, v := range connDps {
+		if sw.err != nil {
+			return
+		}
+
+		num, msg := samplesError(v.err)
+
+		sw.err = sw.csv.Write([]string{
+			SamplesKindConnect,
+			strconv.FormatInt(v.timestamp.UnixNano(), 10),
+			strconv.Itoa(v.agentId),
+			"",
+			strconv.FormatInt(int64(v.resTime), 10),
+			"0",
+			"0",
+			"0",
+			"0",
+			"0",
+			num,
+			msg,
+			"false",
+			"0",
+		})
+	}
+}
+
+// Reconnects of the agent in the phase: "r,TIMESTAMP,AGENT,RECONNECTS,FORCED"
+func (sw *samplesWriter) addReconnects(agentId int, n int, forced int) {
+	sw.Lock()
+	defer sw.Unlock()
+
+	if sw.err != nil {
+		return
+	}
+
+	sw.err = sw.csv.Write([]string{
+		SamplesKindReconnects,
+		strconv.FormatInt(time.Now().UnixNano(), 10),
+		strconv.Itoa(agentId),
+		strconv.Itoa(n),
+		strconv.Itoa(forced),
+	})
+}
+
+func (sw *samplesWriter) close() error {
+	sw.Lock()
+	defer sw.Unlock()
+
+	if sw.err == nil {
+		sw.csv.Flush()
+		sw.err = sw.writer.Flush()
+	}
+
+	if sw.err != nil {
+		sw.file.Close()
+		return sw.err
+	}
+
+	return sw.file.Close()
+}
+
+func samplesError(err error) (string, string) {
+	if err == nil {
+		return "", ""
+	}
+
+	num, msg := errorNumberAndMessage(err)
+
+	return strconv.Itoa(int(num)), msg
+}
+
+// Rebuild the recorder of each phase from a samples file with the given analysis settings
+func ReadSamples(path string, recOpts *RecorderOpts) ([]*Recorder, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	// NOTE: Error messages may contain newlines, so records are not split by lines
+	r := csv.NewReader(bufio.NewReader(file))
+	r.FieldsPerRecord = -1
+	recs := []*Recorder{}
+	var rec *Recorder
+
+	for recNo := 1; ; recNo++ {
+		fields, err := r.Read()
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch fields[0] {
+		case SamplesKindPhase:
+			sp := &samplesPhase{}
+
+			if err := unmarshalSampleJSON(fields, sp); err != nil {
+				return nil, fmt.Errorf("Failed to parse phase (record=%d): %w", recNo, err)
+			}
+
+			sp.TaskOpts.WarmUp = sp.WarmUp
+			opts := *recOpts
+			opts.DSN = sp.DSN
+			rec = newRecorder(&opts, &sp.TaskOpts, &sp.DataOpts, sp.Token)
+			rec.phase = sp.Phase
+			rec.startedAt = sp.StartedAt
+			rec.finishedAt = sp.StartedAt
+			rec.agentStats = map[int]*agentStats{}
+			recs = append(recs, rec)
+		case SamplesKindFinished:
+			if rec == nil {
+				return nil, fmt.Errorf("Phase not started (record=%d)", recNo)
+			}
+
+			sf := &samplesFinished{}
+
+			if err := unmarshalSampleJSON(fields, sf); err != nil {
+				return nil, fmt.Errorf("Failed to parse finished time (record=%d): %w", recNo, err)
+			}
+
+			rec.finishedAt = sf.FinishedAt
+		case samplesHeader[0]:
+			// Header
+		default:
+			if rec == nil {
+				return nil, fmt.Errorf("Phase not started (record=%d)", recNo)
+			}
+
+			if err := rec.addSample(fields); err != nil {
+				return nil, fmt.Errorf("Failed to parse sample (record=%d): %w", recNo, err)
+			}
+		}
+	}
+
+	return recs, nil
+}
+
+func unmarshalSampleJSON(fields []string, v interface{}) error {
+	if len(fields) != 2 {
+		return fmt.Errorf("wrong number of fields: %d", len(fields))
+	}
+
+	return json.Unmarshal([]byte(fields[1]), v)
+}
+
+func (rec *Recorder) addSample(fields []string) error {
+	if fields[0] == SamplesKindReconnects {
+		return rec.addReconnectsSample(fields)
+	}
+
+	if len(fields) != len(samplesHeader) {
+		return fmt.Errorf("wrong number of fields: %d", len(fields))
+	}
+
+	ints := make([]int64, 0, 8)
+
+	for _, i := range []int{1, 2, 4, 5, 6, 7, 8, 9} {
+		n, err := strconv.ParseInt(fields[i], 10, 64)
+
+		if err != nil {
+			return err
+		}
+
+		ints = append(ints, n)
+	}
+
+	var sampleErr error
+
+	if fields[10] != "" {
+		num, err := strconv.Atoi(fields[10])
+
+		if err != nil {
+			return err
+		}
+
+		if num != 0 {
+			sampleErr = &mysql.MySQLError{Number: uint16(num), Message: fields[11]}
+		} else {
+			sampleErr = errors.New(fields[11])
+		}
+	}
+
+	connLost, err := strconv.ParseBool(fields[12])
+
+	if err != nil {
+		return err
+	}
+
+	workerId, err := strconv.Atoi(fields[13])
+
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Unix(0, ints[0])
+
+	// NOTE: If the phase was interrupted, its finished time is the last sample
+	if timestamp.After(rec.finishedAt) {
+		rec.finishedAt = timestamp
+	}
+
+	switch fields[0] {
+	case SamplesKindQuery:
+		rec.appendDataPoints([]recorderDataPoint{{
+			timestamp:  timestamp,
+			agentId:    int(ints[1]),
+			workerId:   workerId,
+			tag:        fields[3],
+			resTime:    time.Duration(ints[2]),
+			schedDelay: time.Duration(ints[3]),
+			rowCnt:     int(ints[4]),
+			byteCnt:    int(ints[5]),
+			err:        sampleErr,
+			connLost:   connLost,
+		}})
+	case SamplesKindTransaction:
+		rec.addTransactions([]recorderTxDataPoint{{
+			timestamp:   timestamp,
+			agentId:     int(ints[1]),
+			resTime:     time.Duration(ints[2]),
+			rollbackCnt: int(ints[6]),
+			retryCnt:    int(ints[7]),
+			err:         sampleErr,
+		}})
+	case SamplesKindConnect:
+		rec.addConnects([]recorderConnectDataPoint{{
+			timestamp: timestamp,
+			agentId:   int(ints[1]),
+			resTime:   time.Duration(ints[2]),
+			err:       sampleErr,
+		}})
+	default:
+		return fmt.Errorf("unknown kind: %s", fields[0])
+	}
+
+	return nil
+}
+
+func (rec *Recorder) addReconnectsSample(fields []string) error {
+	if len(fields) != 5 {
+		return fmt.Errorf("wrong number of fields: %d", len(fields))
+	}
+
+	ints := make([]int, 0, 3)
+
+	for _, f := range fields[2:] {
+		n, err := strconv.Atoi(f)
+
+		if err != nil {
+			return err
+		}
+
+		ints = append(ints, n)
+	}
+
+	rec.addReconnects(ints[0], ints[1], ints[2])
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package qlap
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func writeSamplesPhase(t *testing.T, sw *samplesWriter, recOpts *RecorderOpts, taskOpts *TaskOpts, phase string, startedAt time.Time) *Recorder {
+	rec := newRecorder(recOpts, taskOpts, &DataOpts{LoadType: LoadTypeMixed, Transaction: true}, "token")
+	rec.phase = phase
+	rec.samples = sw
+	rec.agentStats = map[int]*agentStats{}
+	rec.startedAt = startedAt
+	sw.startPhase(rec)
+
+	at := func(d time.Duration) time.Time { return startedAt.Add(d) }
+	dps := []recorderDataPoint{}
+
+	for i := 0; i < 300; i++ {
+		agentId := i % 2
+		tag := StatementTagSelect
+
+		if i%3 == 0 {
+			tag = StatementTagInsert
+		}
+
+		dps = append(dps, recorderDataPoint{
+			timestamp:  at(time.Duration(i) * 10 * time.Millisecond),
+			agentId:    agentId,
+			workerId:   i%4 + 1,
+			tag:        tag,
+			resTime:    time.Duration(i+1) * 100 * time.Microsecond,
+			schedDelay: time.Duration(i%5) * time.Millisecond,
+			rowCnt:     1,
+			byteCnt:    10,
+		})
+	}
+
+	dps = append(dps,
+		recorderDataPoint{timestamp: at(2 * time.Second), agentId: 0, workerId: 1, tag: StatementTagSelect, resTime: time.Millisecond,
+			err: &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}},
+		recorderDataPoint{timestamp: at(2100 * time.Millisecond), agentId: 1, workerId: 2, tag: StatementTagInsert, resTime: time.Millisecond,
+			err: errors.New("invalid connection\nwith a newline"), connLost: true},
+		recorderDataPoint{timestamp: at(2500 * time.Millisecond), agentId: 1, workerId: 2, tag: StatementTagInsert, resTime: time.Millisecond},
+	)
+
+	rec.appendDataPoints(dps)
+	rec.addTransactions([]recorderTxDataPoint{
+		{timestamp: at(500 * time.Millisecond), agentId: 0, resTime: time.Millisecond},
+		{timestamp: at(1500 * time.Millisecond), agentId: 0, resTime: 2 * time.Millisecond, rollbackCnt: 1, retryCnt: 1},
+		{timestamp: at(2500 * time.Millisecond), agentId: 1, resTime: 3 * time.Millisecond, err: errors.New("tx error")},
+	})
+	rec.addConnects([]recorderConnectDataPoint{
+		{timestamp: at(500 * time.Millisecond), agentId: 0, resTime: time.Millisecond},
+		{timestamp: at(1500 * time.Millisecond), agentId: 1, resTime: 2 * time.Millisecond},
+		{timestamp: at(1600 * time.Millisecond), agentId: 1, resTime: 3 * time.Millisecond, err: errors.New("refused")},
+	})
+	rec.addReconnects(0, 2, 5)
+	rec.addReconnects(1, 1, 0)
+	rec.finishedAt = at(3 * time.Second)
+
+	if err := sw.finishPhase(rec.finishedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	return rec
+}
+
+func TestSamplesRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "samples.csv")
+	sw, err := newSamplesWriter(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recOpts := &RecorderOpts{
+		DSN:                "root@/qlap",
+		HistogramPrecision: DefaultHistogramPrecision,
+		Percentiles:        []float64{90, 99.9},
+		AgentStats:         true,
+	}
+
+	startedAt := time.Unix(1600000000, 123456789)
+	taskOpts := &TaskOpts{NAgents: 2, WarmUp: time.Second, OpenLoop: true, Rate: 100}
+	rec1 := writeSamplesPhase(t, sw, recOpts, taskOpts, "open-loop", startedAt)
+	rec2 := writeSamplesPhase(t, sw, recOpts, &TaskOpts{NAgents: 2}, "closed-loop", startedAt.Add(time.Minute))
+
+	if err := sw.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := ReadSamples(path, recOpts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recs) != 2 {
+		t.Fatalf("len(recs) = %d, want 2", len(recs))
+	}
+
+	for i, want := range []*Recorder{rec1, rec2} {
+		wantReport := want.Report()
+		gotReport := recs[i].Report()
+
+		if gotReport.Phase != want.phase {
+			t.Errorf("phase #%d: Phase = %q, want %q", i, gotReport.Phase, want.phase)
+		}
+
+		wantJSON, _ := json.Marshal(wantReport)
+		gotJSON, _ := json.Marshal(gotReport)
+
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("phase #%d: rebuilt report differs:\n got: %s\nwant: %s", i, gotJSON, wantJSON)
+		}
+	}
+
+	openLoop := recs[0].Report()
+
+	// Samples during warm-up are left out
+	if openLoop.QueryCount != 201 || openLoop.ErrorCount != 2 {
+		t.Errorf("QueryCount/ErrorCount = %d/%d, want 201/2", openLoop.QueryCount, openLoop.ErrorCount)
+	}
+
+	if openLoop.CorrectedResponse == nil || openLoop.CorrectedResponse.Time.Max <= openLoop.Response.Time.Max {
+		t.Errorf("corrected response is not rebuilt: %+v", openLoop.CorrectedResponse)
+	}
+
+	if tr := openLoop.Transaction; tr == nil || tr.TransactionCount != 1 || tr.ErrorCount != 1 || tr.RetryCount != 1 {
+		t.Errorf("Transaction = %+v, want 1 transaction, 1 error and 1 retry", tr)
+	}
+
+	reconnects, forced := 0, 0
+
+	for _, ar := range openLoop.Agents {
+		reconnects += ar.Reconnects
+		forced += ar.ForcedReconnects
+	}
+
+	if reconnects != 3 || forced != 5 {
+		t.Errorf("Reconnects/ForcedReconnects = %d/%d, want 3/5", reconnects, forced)
+	}
+
+	if closedLoop := recs[1].Report(); closedLoop.QueryCount != 301 || closedLoop.CorrectedResponse != nil {
+		t.Errorf("closed loop: QueryCount = %d, CorrectedResponse = %+v", closedLoop.QueryCount, closedLoop.CorrectedResponse)
+	}
+}
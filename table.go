@@ -0,0 +1,443 @@
+package qlap
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Existing table to generate load against instead of the auto-generated table
+type tableSchema struct {
+	name          string
+	primaryKey    *column
+	autoIncrement bool      // The primary key is generated by the server
+	columns       []*column // Columns to be written, except for the primary key
+	indexed       []*column // Columns to be written with indexes usable for sorting
+	selectList    string
+	numKeys       int // Number of sampled primary keys
+}
+
+// Column definition read from information_schema.COLUMNS
+type tableColumn struct {
+	name       string
+	dataType   string
+	columnType string
+	charLen    sql.NullInt64
+	precision  sql.NullInt64
+	scale      sql.NullInt64
+	nullable   bool
+	hasDefault bool
+	key        string // "PRI", "UNI" or "MUL" if indexed
+	extra      string
+}
+
+func introspectTable(db DB, dbName string, tblName string) (*tableSchema, error) {
+	tblCols, err := readTableColumns(db, dbName, tblName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tblCols) == 0 {
+		return nil, fmt.Errorf("Table not found: %s.%s", dbName, tblName)
+	}
+
+	pkNames := []string{}
+
+	for _, tc := range tblCols {
+		if tc.key == "PRI" {
+			pkNames = append(pkNames, tc.name)
+		}
+	}
+
+	if len(pkNames) == 0 {
+		return nil, fmt.Errorf("Table has no primary key: %s", tblName)
+	} else if len(pkNames) > 1 {
+		return nil, fmt.Errorf("Composite primary key is not supported: %s (%s)", tblName, strings.Join(pkNames, ","))
+	}
+
+	schema := &tableSchema{name: tblName}
+	selectList := make([]string, len(tblCols))
+
+	for i, tc := range tblCols {
+		selectList[i] = quoteIdentifier(tc.name)
+		col := tc.column()
+		autoIncrement := strings.Contains(strings.ToLower(tc.extra), "auto_increment")
+
+		if tc.name == pkNames[0] {
+			if col == nil && !autoIncrement {
+				return nil, fmt.Errorf("Unsupported type of primary key: %s (%s)", tc.name, tc.columnType)
+			}
+
+			schema.primaryKey = &column{name: tc.name}
+
+			if col != nil {
+				schema.primaryKey = col
+			}
+
+			schema.autoIncrement = autoIncrement
+
+			continue
+		}
+
+		// NOTE: Generated columns cannot be written
+		if autoIncrement || strings.Contains(strings.ToUpper(tc.extra), "GENERATED") {
+			continue
+		}
+
+		if col == nil {
+			if tc.nullable || tc.hasDefault {
+				continue
+			}
+
+			return nil, fmt.Errorf("Unsupported column type: %s (%s)", tc.name, tc.columnType)
+		}
+
+		schema.columns = append(schema.columns, col)
+
+		if (tc.key == "UNI" || tc.key == "MUL") && col.spec.Type != ColumnTypeText && col.spec.Type != ColumnTypeBlob {
+			schema.indexed = append(schema.indexed, col)
+		}
+	}
+
+	if len(schema.columns) == 0 {
+		return nil, fmt.Errorf("Table has no columns to write: %s", tblName)
+	}
+
+	schema.selectList = strings.Join(selectList, ",")
+
+	return schema, nil
+}
+
+func readTableColumns(db DB, dbName string, tblName string) ([]*tableColumn, error) {
+	rs, err := db.Query(`SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, CHARACTER_MAXIMUM_LENGTH, NUMERIC_PRECISION, NUMERIC_SCALE, IS_NULLABLE, COLUMN_DEFAULT, COLUMN_KEY, EXTRA
+		FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION`, dbName, tblName)
+
+	if err != nil {
+		return nil, fmt.Errorf("Fetch columns error: %w", err)
+	}
+
+	defer rs.Close()
+	tblCols := []*tableColumn{}
+
+	for rs.Next() {
+		tc := &tableColumn{}
+		var nullable string
+		var colDefault sql.NullString
+		err = rs.Scan(&tc.name, &tc.dataType, &tc.columnType, &tc.charLen, &tc.precision, &tc.scale, &nullable, &colDefault, &tc.key, &tc.extra)
+
+		if err != nil {
+			return nil, fmt.Errorf("Scan column error: %w", err)
+		}
+
+		tc.dataType = strings.ToLower(tc.dataType)
+		tc.nullable = nullable == "YES"
+		tc.hasDefault = colDefault.Valid
+		tblCols = append(tblCols, tc)
+	}
+
+	return tblCols, rs.Err()
+}
+
+// Sample primary keys uniformly across the table without sorting it.
+// If latest is true, fetch the largest keys in ascending order instead.
+func (schema *tableSchema) sampleKeys(db DB, dbName string, n int, latest bool) ([]string, error) {
+	if latest {
+		pk := quoteIdentifier(schema.primaryKey.name)
+		query := fmt.Sprintf("SELECT %s FROM (SELECT %s FROM %s ORDER BY %s DESC LIMIT %d) t ORDER BY %s", pk, pk, quoteIdentifier(schema.name), pk, n, pk)
+		return schema.fetchKeys(db, query, n)
+	}
+
+	var tableRows sql.NullInt64
+	row := db.QueryRow("SELECT TABLE_ROWS FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?", dbName, schema.name)
+	err := row.Scan(&tableRows)
+
+	if err != nil {
+		return nil, fmt.Errorf("Fetch table rows error: %w", err)
+	}
+
+	pk := quoteIdentifier(schema.primaryKey.name)
+	query := fmt.Sprintf("SELECT %s FROM %s", pk, quoteIdentifier(schema.name))
+
+	// NOTE: TABLE_ROWS is an estimate, so sample twice as many rows as needed.
+	// LIMIT would stop the scan in the lower half of the keys.
+	if tableRows.Valid && tableRows.Int64 > int64(n) {
+		query += fmt.Sprintf(" WHERE RAND() < %f", float64(2*n)/float64(tableRows.Int64))
+	}
+
+	return schema.fetchKeys(db, query, n)
+}
+
+// Fetch up to n keys.
+// If the query returns more keys, keep n of them at random (reservoir sampling).
+func (schema *tableSchema) fetchKeys(db DB, query string, n int) ([]string, error) {
+	rs, err := db.Query(query)
+
+	if err != nil {
+		return nil, fmt.Errorf("Fetch keys error: %w", err)
+	}
+
+	defer rs.Close()
+	keys := []string{}
+
+	for i := 0; rs.Next(); i++ {
+		var key string
+		err = rs.Scan(&key)
+
+		if err != nil {
+			return nil, fmt.Errorf("Scan key error: %w", err)
+		}
+
+		if len(keys) < n {
+			keys = append(keys, key)
+		} else if j := rand.Intn(i + 1); j < n {
+			keys[j] = key
+		}
+	}
+
+	if err := rs.Err(); err != nil {
+		return nil, fmt.Errorf("Fetch keys error: %w", err)
+	}
+
+	schema.numKeys = len(keys)
+
+	return keys, nil
+}
+
+// Column with a value generator of the type, or nil if the type is not supported
+func (tc *tableColumn) column() *column {
+	var spec *ColumnSpec
+	var value func(src rand.Source) interface{}
+
+	switch tc.dataType {
+	case "tinyint":
+		spec, value = intColumn(1 << 7)
+	case "smallint":
+		spec, value = intColumn(1 << 15)
+	case "mediumint":
+		spec, value = intColumn(1 << 23)
+	case "int", "integer":
+		spec, value = intColumn(1 << 31)
+	case "bit":
+		spec, value = intColumn(1 << minInt64(tc.precision.Int64, 62))
+	case "bigint":
+		spec = &ColumnSpec{Type: ColumnTypeBigint}
+	case "decimal", "numeric":
+		size := int(minInt64(tc.precision.Int64, MaxDecimalPrecision))
+		scale := int(minInt64(tc.scale.Int64, int64(size)))
+		spec = &ColumnSpec{Type: ColumnTypeDecimal, Size: size, Scale: scale}
+	case "float", "double", "real":
+		spec = &ColumnSpec{Type: ColumnTypeDecimal, Size: 6, Scale: DefaultDecimalScale}
+	case "char", "varchar":
+		spec = &ColumnSpec{Type: ColumnTypeVarchar, Size: int(minInt64(tc.charLen.Int64, DefaultTextSize))}
+	case "tinytext", "text", "mediumtext", "longtext":
+		spec = &ColumnSpec{Type: ColumnTypeText, Size: int(minInt64(tc.charLen.Int64, DefaultTextSize))}
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		spec = &ColumnSpec{Type: ColumnTypeBlob, Size: int(minInt64(tc.charLen.Int64, DefaultTextSize))}
+	case "datetime", "timestamp":
+		spec = &ColumnSpec{Type: ColumnTypeDatetime}
+	case "date":
+		spec, value = datetimeColumn(0, 10)
+	case "time":
+		spec, value = datetimeColumn(11, 19)
+	case "year":
+		spec, value = datetimeColumn(0, 4)
+	case "json":
+		spec = &ColumnSpec{Type: ColumnTypeJSON, Size: DefaultJSONSize}
+	case "enum", "set":
+		values := parseEnumValues(tc.columnType)
+
+		if len(values) == 0 {
+			return nil
+		}
+
+		spec = &ColumnSpec{Type: ColumnTypeEnum, Values: values}
+	default:
+		return nil
+	}
+
+	if value == nil {
+		value = spec.generator()
+	}
+
+	return &column{name: tc.name, spec: spec, value: value}
+}
+
+// Non-negative integers less than max
+func intColumn(max int64) (*ColumnSpec, func(src rand.Source) interface{}) {
+	return &ColumnSpec{Type: ColumnTypeInt}, func(src rand.Source) interface{} {
+		return src.Int63() % max
+	}
+}
+
+// Part of a DATETIME value, e.g. [0:10] is DATE
+func datetimeColumn(from int, to int) (*ColumnSpec, func(src rand.Source) interface{}) {
+	spec := &ColumnSpec{Type: ColumnTypeDatetime}
+	datetime := spec.generator()
+
+	return spec, func(src rand.Source) interface{} {
+		return datetime(src).(string)[from:to]
+	}
+}
+
+// Values of COLUMN_TYPE, e.g. "enum('a','b')".
+// A quote is a part of the value unless it is followed by a comma or the end.
+func parseEnumValues(columnType string) []string {
+	start := strings.Index(columnType, "(")
+	end := strings.LastIndex(columnType, ")")
+
+	if start < 0 || end < start {
+		return nil
+	}
+
+	values := []string{}
+	var sb strings.Builder
+	quoted := false
+	str := columnType[start+1 : end]
+
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+
+		switch {
+		case !quoted:
+			quoted = c == '\''
+		case c == '\'' && i+1 < len(str) && str[i+1] == '\'':
+			sb.WriteByte(c)
+			i++
+		case c == '\'' && (i+1 == len(str) || str[i+1] == ','):
+			values = append(values, sb.String())
+			sb.Reset()
+			quoted = false
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return values
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func minInt64(a int64, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func (data *Data) buildTableSelectStmt(key bool) *statement {
+	tbl := data.table
+	pk := quoteIdentifier(tbl.primaryKey.name)
+	sb := data.newStmtBuilder(StatementTagSelect)
+	sb.WriteString("SELECT " + tbl.selectList + " FROM " + quoteIdentifier(tbl.name) + " WHERE " + pk)
+
+	if key {
+		sb.WriteString(" = ")
+		sb.writeValue(data.nextId())
+	} else {
+		sb.WriteString(" >= ")
+		sb.writeValue(data.nextId())
+		fmt.Fprintf(sb, " ORDER BY %s LIMIT %d", pk, data.RangeSize)
+	}
+
+	return sb.statement()
+}
+
+func (data *Data) buildTableInsertStmt() *statement {
+	tbl := data.table
+	cols := tbl.columns
+
+	if !tbl.autoIncrement {
+		cols = append([]*column{tbl.primaryKey}, cols...)
+	}
+
+	sb := data.newStmtBuilder(StatementTagInsert)
+	sb.WriteString("INSERT INTO " + quoteIdentifier(tbl.name) + " (")
+
+	for i, col := range cols {
+		if i >= 1 {
+			sb.WriteString(",")
+		}
+
+		sb.WriteString(quoteIdentifier(col.name))
+	}
+
+	sb.WriteString(") VALUES (")
+	var key interface{}
+
+	for i, col := range cols {
+		if i >= 1 {
+			sb.WriteString(",")
+		}
+
+		v := col.value(data.randSrc)
+
+		if col == tbl.primaryKey {
+			key = v
+		}
+
+		sb.writeValue(v)
+	}
+
+	sb.WriteString(")")
+	stmt := sb.statement()
+
+	if data.latest != nil {
+		stmt.afterExec = func(res sql.Result) {
+			if key != nil {
+				data.latest.add(fmt.Sprint(key))
+			} else if id, err := res.LastInsertId(); err == nil && id > 0 {
+				data.latest.add(strconv.FormatInt(id, 10))
+			}
+		}
+	}
+
+	return stmt
+}
+
+func (data *Data) buildTableUpdateStmt() *statement {
+	tbl := data.table
+	sb := data.newStmtBuilder(StatementTagUpdate)
+	sb.WriteString("UPDATE " + quoteIdentifier(tbl.name) + " SET ")
+
+	for i, col := range tbl.columns {
+		if i >= 1 {
+			sb.WriteString(",")
+		}
+
+		sb.WriteString(quoteIdentifier(col.name) + " = ")
+		sb.writeValue(col.value(data.randSrc))
+	}
+
+	sb.WriteString(" WHERE " + quoteIdentifier(tbl.primaryKey.name) + " = ")
+	sb.writeValue(data.nextId())
+
+	return sb.statement()
+}
+
+func (data *Data) buildTableKeyedInsertStmt(tag string, verb string, id string) *stmtBuilder {
+	tbl := data.table
+	sb := data.newStmtBuilder(tag)
+	sb.WriteString(verb + " " + quoteIdentifier(tbl.name) + " (" + quoteIdentifier(tbl.primaryKey.name))
+
+	for _, col := range tbl.columns {
+		sb.WriteString("," + quoteIdentifier(col.name))
+	}
+
+	sb.WriteString(") VALUES (")
+	sb.writeValue(id)
+
+	for _, col := range tbl.columns {
+		sb.WriteString(",")
+		sb.writeValue(col.value(data.randSrc))
+	}
+
+	sb.WriteString(")")
+
+	return sb
+}
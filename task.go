@@ -18,6 +18,21 @@ const (
 	ProgressReportPeriod = 1
 )
 
+type ErrorPolicy string
+
+const (
+	ErrorPolicyAbort    = ErrorPolicy("abort")
+	ErrorPolicyContinue = ErrorPolicy("continue")
+)
+
+type ConnectionMode string
+
+const (
+	ConnectionModePersistent     = ConnectionMode("persistent")
+	ConnectionModePerQuery       = ConnectionMode("per-query")
+	ConnectionModePerTransaction = ConnectionMode("per-transaction")
+)
+
 type TaskOpts struct {
 	MysqlConfig            *MysqlConfig `json:"-"`
 	NAgents                int
@@ -25,11 +40,23 @@ type TaskOpts struct {
 	Rate                   int
 	AutoGenerateSql        bool
 	NumberPrePopulatedData int
+	NumberTableKeys        int // Number of primary keys sampled from the existing table
 	NumberQueriesToExecute int
 	DropExistingDatabase   bool
 	UseExistingDatabase    bool
 	NoDropDatabase         bool
 	Engine                 string
+	RateStages             []RateStage
+	WarmUp                 time.Duration `json:"-"`
+	OpenLoop               bool
+	OpenLoopWorkers        int
+	OpenLoopMaxWorkers     int
+	OnError                ErrorPolicy
+	MaxErrors              int
+	ConnectionMode         ConnectionMode
+	ReconnectEvery         int // Number of queries per connection in per-query mode
+	AutoReconnect          bool
+	MaxReconnectBackoff    time.Duration
 	Creates                []string `json:"-"`
 	OnlyPrint              bool     `json:"-"`
 	NoProgress             bool     `json:"-"`
@@ -37,9 +64,13 @@ type TaskOpts struct {
 
 type Task struct {
 	*TaskOpts
-	agents   []*Agent
-	dataOpts *DataOpts
-	recOpts  *RecorderOpts
+	agents     []*Agent
+	dataOpts   *DataOpts
+	recOpts    *RecorderOpts
+	metrics    *Metrics
+	timeSeries *timeSeries
+	samples    *samplesWriter
+	table      *tableSchema
 }
 
 func init() {
@@ -64,14 +95,32 @@ func NewTask(taskOpts *TaskOpts, dataOpts *DataOpts, recOpts *RecorderOpts) (tas
 }
 
 func (task *Task) Prepare() error {
+	templates, err := parseQueryTemplates(task.dataOpts.Queries)
+
+	if err != nil {
+		return fmt.Errorf("Failed to parse queries: %w", err)
+	}
+
 	idList, err := task.setupDB()
 
 	if err != nil {
 		return fmt.Errorf("Failed to setup DB: %w", err)
 	}
 
+	// NOTE: Hot keys are shared by all agents to cause contention
+	if task.dataOpts.KeyDistribution == KeyDistributionZipfian || task.dataOpts.KeyDistribution == KeyDistributionHotspot {
+		rand.Shuffle(len(idList), func(i, j int) { idList[i], idList[j] = idList[j], idList[i] })
+	}
+
+	// NOTE: Agents read the new keys of each other
+	var latest *latestKeys
+
+	if task.dataOpts.KeyDistribution == KeyDistributionLatest {
+		latest = newLatestKeys(idList, task.dataOpts.ZipfianTheta)
+	}
+
 	for _, agent := range task.agents {
-		if err := agent.prepare(task.NAgents, idList); err != nil {
+		if err := agent.prepare(task.NAgents, idList, latest, templates, task.table); err != nil {
 			return fmt.Errorf("Failed to prepare Agent: %w", err)
 		}
 	}
@@ -124,6 +173,10 @@ func (task *Task) setupDB() ([]string, error) {
 	}
 
 	if dbCnt < 1 {
+		if task.dataOpts.Table != "" {
+			return nil, fmt.Errorf("Database not found: %s", task.MysqlConfig.DBName)
+		}
+
 		_, err = db.Exec(fmt.Sprintf("CREATE DATABASE `%s`", task.MysqlConfig.DBName))
 
 		if err != nil {
@@ -139,6 +192,10 @@ func (task *Task) setupDB() ([]string, error) {
 		return nil, fmt.Errorf("Use database error: %w", err)
 	}
 
+	if task.dataOpts.Table != "" {
+		return task.setupTable(db)
+	}
+
 	if len(task.Creates) > 0 {
 		for _, stmt := range task.Creates {
 			_, err = db.Exec(stmt)
@@ -157,7 +214,7 @@ func (task *Task) setupDB() ([]string, error) {
 		return nil, fmt.Errorf("Drop table error: %w", err)
 	}
 
-	tblStmt := newData(task.dataOpts, nil).buildCreateTableStmt()
+	tblStmt := newData(task.dataOpts, nil, nil, nil, nil).buildCreateTableStmt()
 	_, err = db.Exec(tblStmt)
 
 	if err != nil {
@@ -176,7 +233,7 @@ func (task *Task) setupDB() ([]string, error) {
 	}
 
 	idList := make([]string, task.NumberPrePopulatedData*task.NAgents)
-	rs, err := db.Query("SELECT id FROM t1")
+	rs, err := db.Query("SELECT id FROM t1 ORDER BY id")
 
 	if _, ok := db.(*NullDB); ok {
 		return idList, nil
@@ -197,12 +254,31 @@ func (task *Task) setupDB() ([]string, error) {
 	return idList, nil
 }
 
+// Read the schema of the existing table and sample its primary keys
+func (task *Task) setupTable(db DB) ([]string, error) {
+	table, err := introspectTable(db, task.MysqlConfig.DBName, task.dataOpts.Table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	idList, err := table.sampleKeys(db, task.MysqlConfig.DBName, task.NumberTableKeys, task.dataOpts.KeyDistribution == KeyDistributionLatest)
+
+	if err != nil {
+		return nil, err
+	}
+
+	task.table = table
+
+	return idList, nil
+}
+
 func (task *Task) prePopulateData(ctx context.Context) *errgroup.Group {
 	eg, ctx := errgroup.WithContext(ctx)
 
 	for i := 0; i < task.NAgents; i++ {
 		eg.Go(func() error {
-			data := newData(task.dataOpts, nil)
+			data := newData(task.dataOpts, nil, nil, nil, nil)
 			db, err := task.MysqlConfig.openAndPing(1)
 
 			if err != nil {
@@ -217,10 +293,10 @@ func (task *Task) prePopulateData(ctx context.Context) *errgroup.Group {
 					return nil
 				default:
 					insStmt := data.buildInsertStmt()
-					_, err = db.Exec(insStmt)
+					_, err = db.Exec(insStmt.sql, insStmt.args...)
 
 					if err != nil {
-						return fmt.Errorf("Insert error (query=%s): %w", insStmt, err)
+						return fmt.Errorf("Insert error (query=%s): %w", insStmt.sql, err)
 					}
 				}
 			}
@@ -233,26 +309,79 @@ func (task *Task) prePopulateData(ctx context.Context) *errgroup.Group {
 }
 
 func (task *Task) Run() (*Recorder, error) {
+	return task.RunPhase(task.defaultPhase())
+}
+
+// Run agents with the settings of the phase.
+// The prepared database and connections are reused across phases.
+func (task *Task) RunPhase(phase *Phase) (*Recorder, error) {
+	if phase.NAgents > len(task.agents) {
+		return nil, fmt.Errorf("Number of agents in the phase exceeds the prepared agents (phase=%s, nagents=%d, prepared=%d)", phase.Name, phase.NAgents, len(task.agents))
+	}
+
+	if task.table != nil && task.table.numKeys == 0 && phase.LoadType != LoadTypeWrite {
+		return nil, fmt.Errorf("No rows in the table for '%s' load: %s", phase.LoadType, task.table.name)
+	}
+
+	taskOpts, dataOpts := phase.apply(task.TaskOpts, task.dataOpts)
+	agents := task.agents[:phase.NAgents]
+
+	for _, agent := range agents {
+		agent.setOpts(taskOpts, dataOpts)
+	}
+
 	uuid, _ := uuid.NewRandom()
 	token := uuid.String()
-	rec := newRecorder(task.recOpts, task.TaskOpts, task.dataOpts, token)
+	rec := newRecorder(task.recOpts, taskOpts, dataOpts, token)
+	rec.phase = phase.Name
 
-	defer func() {
-		rec.close()
+	if task.recOpts.MetricsAddr != "" && task.metrics == nil {
+		metrics := newMetrics()
+		err := metrics.listen(task.recOpts.MetricsAddr)
 
-		for _, agent := range task.agents {
-			err := agent.close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to listen for metrics: %w", err)
+		}
 
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "[WARN] Failed to cloge Agent: %s", err)
-			}
+		task.metrics = metrics
+	}
+
+	if task.recOpts.TimeSeries != "" && task.timeSeries == nil {
+		ts, err := newTimeSeries(task.recOpts.TimeSeries, task.recOpts.TimeSeriesFormat, time.Now())
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open time series file: %w", err)
+		}
+
+		task.timeSeries = ts
+	}
+
+	if task.recOpts.Samples != "" && task.samples == nil {
+		sw, err := newSamplesWriter(task.recOpts.Samples)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open samples file: %w", err)
+		}
+
+		task.samples = sw
+	}
+
+	rec.metrics = task.metrics
+	rec.timeSeries = task.timeSeries
+	rec.samples = task.samples
+	rec.start(phase.NAgents * 3)
+
+	defer func() {
+		err := rec.close()
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] Failed to close Recorder: %s", err)
 		}
 	}()
 
 	eg, ctxWithoutCancel := errgroup.WithContext(context.Background())
 	ctx, cancel := context.WithCancel(ctxWithoutCancel)
 	progressTick := time.NewTicker(ProgressReportPeriod * time.Second)
-	rec.start(task.NAgents * 3)
 	var numTermAgents int32
 
 	// Variables for progress line
@@ -260,9 +389,14 @@ func (task *Task) Run() (*Recorder, error) {
 	prevExecCnt := 0
 
 	// Run agents
-	for _, v := range task.agents {
+	for _, v := range agents {
 		agent := v
 		eg.Go(func() error {
+			if rec.metrics != nil {
+				rec.metrics.agentStarted(agent.id, rateAt(phase.Rate, phase.RateStages, 0))
+				defer rec.metrics.agentFinished(agent.id)
+			}
+
 			err := agent.run(ctx, rec, token)
 			atomic.AddInt32(&numTermAgents, 1)
 			return err
@@ -280,8 +414,9 @@ func (task *Task) Run() (*Recorder, error) {
 			case <-progressTick.C:
 				if !task.NoProgress && !task.OnlyPrint {
 					execCnt := rec.Count()
+					errCnt := rec.ErrorCount()
 					termAgentCnt := int(atomic.LoadInt32(&numTermAgents))
-					task.printProgress(execCnt, prevExecCnt, taskStart, termAgentCnt)
+					task.printProgress(phase, execCnt, prevExecCnt, errCnt, taskStart, termAgentCnt)
 					prevExecCnt = execCnt
 				}
 			}
@@ -290,12 +425,12 @@ func (task *Task) Run() (*Recorder, error) {
 
 	// Time-out processing
 	// NOTE: If it is zero, it will not time out
-	if task.Time > 0 {
+	if phase.Time > 0 {
 		go func() {
 			select {
 			case <-ctx.Done():
 				// Nothing to do
-			case <-time.After(task.Time):
+			case <-time.After(phase.Time):
 				cancel()
 			}
 		}()
@@ -318,6 +453,38 @@ func (task *Task) Run() (*Recorder, error) {
 }
 
 func (task *Task) Close() error {
+	for _, agent := range task.agents {
+		err := agent.close()
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] Failed to close Agent: %s", err)
+		}
+	}
+
+	if task.metrics != nil {
+		err := task.metrics.shutdown()
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] Failed to shutdown metrics server: %s", err)
+		}
+	}
+
+	if task.timeSeries != nil {
+		err := task.timeSeries.close(time.Now())
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] Failed to close time series: %s", err)
+		}
+	}
+
+	if task.samples != nil {
+		err := task.samples.close()
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] Failed to close samples: %s", err)
+		}
+	}
+
 	err := task.teardownDB()
 
 	if err != nil {
@@ -346,10 +513,10 @@ func (task *Task) teardownDB() error {
 	return nil
 }
 
-func (task *Task) printProgress(execCnt int, prevExecCnt int, taskStart time.Time, numTermAgents int) {
+func (task *Task) printProgress(phase *Phase, execCnt int, prevExecCnt int, errCnt int, taskStart time.Time, numTermAgents int) {
 	qps := float64(execCnt-prevExecCnt) / ProgressReportPeriod
 	elapsedTime := time.Since(taskStart)
-	numRunAgents := task.NAgents - int(numTermAgents)
+	numRunAgents := phase.NAgents - int(numTermAgents)
 	termWidth, _, err := term.GetSize(0)
 
 	if err != nil {
@@ -360,6 +527,19 @@ func (task *Task) printProgress(execCnt int, prevExecCnt int, taskStart time.Tim
 	min := elapsedTimeSec / time.Minute
 	sec := (elapsedTimeSec - min*time.Minute) / time.Second
 	progressLine := fmt.Sprintf("%02d:%02d | %d agents / run %d queries (%.0f qps)", min, sec, numRunAgents, execCnt, qps)
+
+	if phase.Name != "" {
+		progressLine = phase.Name + " | " + progressLine
+	}
+
+	if elapsedTime < phase.WarmUp {
+		progressLine += " (warming up)"
+	}
+
+	if errCnt > 0 {
+		progressLine += fmt.Sprintf(" / %d errors", errCnt)
+	}
+
 	fmt.Fprintf(os.Stderr, "\r%-*s", termWidth, progressLine)
 }
 
@@ -369,6 +549,9 @@ func (task *Task) trapSigint(ctx context.Context, cancel context.CancelFunc, eg
 	signal.Notify(sgnlCh, os.Interrupt)
 
 	go func() {
+		// NOTE: The trap is installed for each phase
+		defer signal.Stop(sgnlCh)
+
 		select {
 		case <-ctx.Done():
 			// Nothing to do
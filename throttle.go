@@ -6,14 +6,45 @@ const (
 	ThrottleInterrupt = 1 * time.Millisecond
 )
 
-func loopWithThrottle(rate int, proc func(i int) (bool, error)) error {
-	orgLimit := time.Duration(0)
+// Rate changes linearly from FromRate to ToRate during Duration.
+// If FromRate and ToRate are the same, the rate is constant.
+type RateStage struct {
+	FromRate int
+	ToRate   int
+	Duration time.Duration
+}
+
+// Returns the rate limit at the elapsed time.
+// After all stages, the last rate continues.
+func rateAt(rate int, stages []RateStage, elapsed time.Duration) int {
+	if len(stages) == 0 {
+		return rate
+	}
+
+	for _, st := range stages {
+		if elapsed < st.Duration {
+			return st.FromRate + int(int64(st.ToRate-st.FromRate)*int64(elapsed)/int64(st.Duration))
+		}
+
+		elapsed -= st.Duration
+	}
+
+	return stages[len(stages)-1].ToRate
+}
 
-	if rate > 0 {
-		// XXX: Add 1 to get closer to the actual rate...
-		orgLimit = time.Second / time.Duration(rate+1)
+func rateToLimit(rate int) time.Duration {
+	if rate <= 0 {
+		return 0
 	}
 
+	// XXX: Add 1 to get closer to the actual rate...
+	return time.Second / time.Duration(rate+1)
+}
+
+func loopWithThrottle(rate int, stages []RateStage, proc func(i int) (bool, error)) error {
+	loopStart := time.Now()
+	currRate := rateAt(rate, stages, 0)
+	orgLimit := rateToLimit(currRate)
 	thrInt := time.NewTicker(ThrottleInterrupt)
 	defer thrInt.Stop()
 	blockStart := time.Now()
@@ -33,6 +64,21 @@ func loopWithThrottle(rate int, proc func(i int) (bool, error)) error {
 		select {
 		case <-thrInt.C:
 			thrEnd := time.Now()
+
+			if newRate := rateAt(rate, stages, thrEnd.Sub(loopStart)); newRate != currRate {
+				newLimit := rateToLimit(newRate)
+
+				if currRate <= 0 || newRate <= 0 {
+					currLimit = newLimit
+				} else {
+					// Keep the adjustment for the actual rate
+					currLimit += newLimit - orgLimit
+				}
+
+				currRate = newRate
+				orgLimit = newLimit
+			}
+
 			procElapsed := thrEnd.Sub(thrStart)
 			actualLimit := procElapsed / time.Duration(txCnt)
 			currLimit += (orgLimit - actualLimit)
@@ -0,0 +1,220 @@
+package qlap
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type TimeSeriesFormat string
+
+const (
+	TimeSeriesFormatCSV   = TimeSeriesFormat("csv")
+	TimeSeriesFormatJSONL = TimeSeriesFormat("jsonl")
+	TimeSeriesPeriod      = 1 * time.Second
+	// Agents send data points every RecordPeriod,
+	// so wait for a while before writing an interval
+	TimeSeriesLag = 2 * TimeSeriesPeriod
+)
+
+type TimeSeriesRow struct {
+	Timestamp  time.Time `json:"timestamp"`
+	QPS        float64   `json:"qps"`
+	P50        float64   `json:"p50_ms"`
+	P95        float64   `json:"p95_ms"`
+	P99        float64   `json:"p99_ms"`
+	Max        float64   `json:"max_ms"`
+	ErrorCount int       `json:"errors"`
+}
+
+type timeSeriesBucket struct {
+	resTimes []time.Duration
+	errCnt   int
+}
+
+type timeSeries struct {
+	sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	format  TimeSeriesFormat
+	buckets map[int64]*timeSeriesBucket
+	next    int64
+	err     error
+}
+
+func newTimeSeries(path string, format TimeSeriesFormat, startedAt time.Time) (*timeSeries, error) {
+	file, err := os.Create(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &timeSeries{
+		file:    file,
+		writer:  bufio.NewWriter(file),
+		format:  format,
+		buckets: map[int64]*timeSeriesBucket{},
+		next:    startedAt.Unix(),
+	}
+
+	if format == TimeSeriesFormatCSV {
+		_, err = ts.writer.WriteString("timestamp,qps,p50_ms,p95_ms,p99_ms,max_ms,errors\n")
+
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return ts, nil
+}
+
+func (ts *timeSeries) add(recDps []recorderDataPoint) {
+	ts.Lock()
+	defer ts.Unlock()
+
+	for _, v := range recDps {
+		sec := v.timestamp.Unix()
+
+		// NOTE: Data points that arrive after their interval was written
+		//       are counted in the oldest interval that has not been written yet
+		if sec < ts.next {
+			sec = ts.next
+		}
+
+		b, ok := ts.buckets[sec]
+
+		if !ok {
+			b = &timeSeriesBucket{}
+			ts.buckets[sec] = b
+		}
+
+		if v.err != nil {
+			b.errCnt++
+		} else {
+			b.resTimes = append(b.resTimes, v.resTime)
+		}
+	}
+}
+
+// Write the intervals before "until"
+func (ts *timeSeries) flush(until time.Time) error {
+	ts.Lock()
+	defer ts.Unlock()
+
+	// Stop writing after the first error
+	if ts.err != nil {
+		return ts.err
+	}
+
+	for ; ts.next < until.Unix(); ts.next++ {
+		b, ok := ts.buckets[ts.next]
+
+		if !ok {
+			b = &timeSeriesBucket{}
+		}
+
+		delete(ts.buckets, ts.next)
+		ts.err = ts.writeRow(b.row(time.Unix(ts.next, 0)))
+
+		if ts.err != nil {
+			return ts.err
+		}
+	}
+
+	ts.err = ts.writer.Flush()
+
+	return ts.err
+}
+
+func (ts *timeSeries) writeRow(row *TimeSeriesRow) error {
+	if ts.format == TimeSeriesFormatJSONL {
+		rawJson, err := json.Marshal(row)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = ts.writer.Write(append(rawJson, '\n'))
+
+		return err
+	}
+
+	w := csv.NewWriter(ts.writer)
+
+	err := w.Write([]string{
+		row.Timestamp.Format(time.RFC3339),
+		strconv.FormatFloat(row.QPS, 'f', -1, 64),
+		strconv.FormatFloat(row.P50, 'f', 3, 64),
+		strconv.FormatFloat(row.P95, 'f', 3, 64),
+		strconv.FormatFloat(row.P99, 'f', 3, 64),
+		strconv.FormatFloat(row.Max, 'f', 3, 64),
+		strconv.Itoa(row.ErrorCount),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
+
+func (ts *timeSeries) close(finishedAt time.Time) error {
+	// Write all the remaining intervals including the last partial interval
+	err := ts.flush(finishedAt.Add(TimeSeriesPeriod))
+
+	if err != nil {
+		ts.file.Close()
+		return fmt.Errorf("Failed to write time series: %w", err)
+	}
+
+	return ts.file.Close()
+}
+
+func (b *timeSeriesBucket) row(timestamp time.Time) *TimeSeriesRow {
+	row := &TimeSeriesRow{
+		Timestamp:  timestamp,
+		QPS:        float64(len(b.resTimes)) / TimeSeriesPeriod.Seconds(),
+		ErrorCount: b.errCnt,
+	}
+
+	if len(b.resTimes) == 0 {
+		return row
+	}
+
+	sort.Slice(b.resTimes, func(i, j int) bool {
+		return b.resTimes[i] < b.resTimes[j]
+	})
+
+	row.P50 = durationToMs(percentile(b.resTimes, 0.50))
+	row.P95 = durationToMs(percentile(b.resTimes, 0.95))
+	row.P99 = durationToMs(percentile(b.resTimes, 0.99))
+	row.Max = durationToMs(b.resTimes[len(b.resTimes)-1])
+
+	return row
+}
+
+// "sorted" must be sorted in ascending order
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(float64(len(sorted))*p+0.5) - 1
+
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+
+	return sorted[idx]
+}
+
+func durationToMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}